@@ -0,0 +1,42 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrNotExist blob does not exist. BlobStore adapters return it from Get.
+var ErrNotExist = xerrors.New("Blob Not Exist")
+
+// BlobStore key-value storage of blobs, e.g. bucket of object storage.
+// Engine implements GOTV+ on top of BlobStore, so new storage provider only needs an adapter.
+type BlobStore interface {
+	// Put stores r as blob key, replacing existing blob. It returns size of the blob.
+	Put(ctx context.Context, key string, r io.Reader, attrs Attrs) (int64, error)
+	// Get opens blob key. It returns ErrNotExist if the blob does not exist.
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	// Delete deletes blob key. Blob which does not exist is ignored.
+	Delete(ctx context.Context, key string) error
+	// List returns every blob whose key starts with prefix.
+	// Info.Metadata is only needed if metadata is true, so adapters can skip fetching it.
+	List(ctx context.Context, prefix string, metadata bool) ([]Info, error)
+}
+
+// Attrs attributes of blob to store
+type Attrs struct {
+	ContentType  string
+	CacheControl string            // empty uses default of storage
+	StorageClass string            // empty uses default of bucket
+	Metadata     map[string]string // keys are lower case
+}
+
+// Info stored blob
+type Info struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+	Metadata map[string]string // keys are lower case
+}
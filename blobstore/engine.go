@@ -0,0 +1,754 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+//
+// BlobStore GOTV+ Engine
+//
+// Each match is stored under "<prefix><token>/":
+//
+//	<prefix><token>/match.json        match metadata written on start
+//	<prefix><token>/<fragment>_start  start fragment
+//	<prefix><token>/<fragment>_full   full fragment. tick and time are kept in blob metadata
+//	<prefix><token>/<fragment>_delta  delta fragment. endtick, final and time are kept in blob metadata
+//
+// Sync state is cached in memory, and rebuilt from BlobStore once it is older than WithCacheTTL,
+// so relays sharing a bucket see fragments and signup fragments written by each other.
+// Token must not contain "/", because each match is listed by its prefix.
+// Fragments are never overwritten with different content, so they can be cached by CDN in front of the bucket.
+
+var _ gotv.StoreV2 = (*Engine)(nil)
+var _ gotv.BroadcasterV2 = (*Engine)(nil)
+var _ gotv.StreamStore = (*Engine)(nil)
+var _ gotv.StreamBroadcaster = (*Engine)(nil)
+var _ gotv.RedirectBroadcaster = (*Engine)(nil)
+var _ gotv.FragmentMetadataBroadcaster = (*Engine)(nil)
+var _ gotv.SizeBroadcaster = (*Engine)(nil)
+var _ gotv.Deleter = (*Engine)(nil)
+var _ gotv.DelayBroadcaster = (*Engine)(nil)
+var _ gotv.MatchInfoBroadcaster = (*Engine)(nil)
+
+// ErrInvalidToken token contains "/"
+var ErrInvalidToken = xerrors.New("Invalid Token")
+
+// Engine BlobStore based GOTV+ Broadcasting Engine
+type Engine struct {
+	sync.RWMutex
+	b            BlobStore            // storage of fragments
+	password     string               // password
+	delay        time.Duration        // broadcast delay like tv_delay
+	prefix       string               // blob key prefix
+	storageClass string               // storage class of blobs. bucket default if empty
+	cacheControl string               // Cache-Control of fragments
+	redirect     string               // base URL of blobs. fragments are served through relay if empty
+	match        map[string]*match    // key=token value=cached sync state
+	cacheTTL     time.Duration        // how long cached sync state is used before it is rebuilt from BlobStore
+	missing      map[string]time.Time // key=token value=expiry. tokens without match.json, not to look them up on every request
+	missingTTL   time.Duration        // how long unknown tokens are remembered
+}
+
+// maxMissing number of unknown tokens to remember before expired ones are swept
+const maxMissing = 1024
+
+// matchMetadata match.json
+type matchMetadata struct {
+	SignupFragment   int       `json:"signup_fragment"`
+	At               time.Time `json:"at"`
+	TickPerSecond    float64   `json:"tps"`
+	KeyframeInterval float64   `json:"keyframe_interval,omitempty"`
+	Map              string    `json:"map"`
+	Protocol         int       `json:"protocol"`
+	Delay            *int64    `json:"delay_ms,omitempty"` // broadcast delay overridden by SetMatchDelay
+}
+
+// match cached sync state of a match
+type match struct {
+	Meta             matchMetadata
+	ReceivedAt       time.Time
+	Latest           int
+	KeyframeInterval float64 // from start request, or estimated from full fragments
+	Delay            time.Duration
+	Start            int64             // size of start fragment
+	Fragments        map[int]*fragment // key=fragment_number
+	loaded           time.Time         // time the match was read from BlobStore or started on this relay
+}
+
+// fragment cached state of full and delta fragment
+type fragment struct {
+	At       time.Time
+	Tick     int
+	EndTick  int
+	Final    bool
+	Full     int64 // size of full fragment
+	Delta    int64 // size of delta fragment
+	HasFull  bool
+	HasDelta bool
+}
+
+func newMatch(meta matchMetadata, delay time.Duration) *match {
+	if meta.Delay != nil {
+		delay = time.Duration(*meta.Delay) * time.Millisecond
+	}
+	return &match{
+		Meta:             meta,
+		ReceivedAt:       meta.At,
+		KeyframeInterval: meta.KeyframeInterval,
+		Delay:            delay,
+		Fragments:        map[int]*fragment{},
+	}
+}
+
+// fragmentOf returns cached fragment n, creating it if not exist
+func (m *match) fragmentOf(n int) *fragment {
+	f, ok := m.Fragments[n]
+	if !ok {
+		f = &fragment{}
+		m.Fragments[n] = f
+	}
+	return f
+}
+
+func (m *match) onFull(n int, tick int, at time.Time, size int64) {
+	f := m.fragmentOf(n)
+	f.At = at
+	f.Tick = tick
+	f.Full = size
+	f.HasFull = true
+	if n > m.Latest {
+		m.Latest = n
+	}
+	if at.After(m.ReceivedAt) {
+		m.ReceivedAt = at
+	}
+	m.estimateKeyframeInterval(n)
+}
+
+func (m *match) onDelta(n int, endtick int, final bool, at time.Time, size int64) {
+	f := m.fragmentOf(n)
+	if f.At.IsZero() {
+		f.At = at
+	}
+	f.EndTick = endtick
+	f.Final = final
+	f.Delta = size
+	f.HasDelta = true
+	if at.After(m.ReceivedAt) {
+		m.ReceivedAt = at
+	}
+}
+
+// estimateKeyframeInterval estimates keyframe interval from fragment and the previous one
+// unless game server sent it in start request
+func (m *match) estimateKeyframeInterval(n int) {
+	if m.Meta.KeyframeInterval > 0 {
+		return
+	}
+	prev, ok := m.Fragments[n-1]
+	if !ok || !prev.HasFull {
+		return
+	}
+	cur := m.Fragments[n]
+	if k := gotv.EstimateKeyframeInterval(
+		gotv.FragmentInfo{At: prev.At, Tick: prev.Tick},
+		gotv.FragmentInfo{At: cur.At, Tick: cur.Tick},
+		m.Meta.TickPerSecond,
+	); k > 0 {
+		m.KeyframeInterval = k
+	}
+}
+
+// MatchInfo implements gotv.SyncSource
+func (m *match) MatchInfo() gotv.MatchInfo {
+	return gotv.MatchInfo{
+		SignupFragment:   m.Meta.SignupFragment,
+		Latest:           m.Latest,
+		ReceivedAt:       m.ReceivedAt,
+		TickPerSecond:    m.Meta.TickPerSecond,
+		KeyframeInterval: m.KeyframeInterval,
+		Map:              m.Meta.Map,
+		Protocol:         m.Meta.Protocol,
+		Delay:            m.Delay,
+	}
+}
+
+// FragmentInfo implements gotv.SyncSource
+func (m *match) FragmentInfo(n int) (gotv.FragmentInfo, bool) {
+	f, ok := m.Fragments[n]
+	if !ok {
+		return gotv.FragmentInfo{}, false
+	}
+	return gotv.FragmentInfo{
+		At:       f.At,
+		Tick:     f.Tick,
+		EndTick:  f.EndTick,
+		Final:    f.Final,
+		HasFull:  f.HasFull,
+		HasDelta: f.HasDelta,
+	}, true
+}
+
+func (e *Engine) matchPrefix(token string) string {
+	return e.prefix + token + "/"
+}
+func (e *Engine) matchKey(token string) string {
+	return e.matchPrefix(token) + "match.json"
+}
+func (e *Engine) frameKey(token string, fragment int, field string) string {
+	return e.matchPrefix(token) + strconv.Itoa(fragment) + "_" + field
+}
+
+// putFrame stores fragment r as blob key
+func (e *Engine) putFrame(ctx context.Context, key string, metadata map[string]string, r io.Reader) (int64, error) {
+	return e.b.Put(ctx, key, r, Attrs{
+		ContentType:  "application/octet-stream",
+		CacheControl: e.cacheControl,
+		StorageClass: e.storageClass,
+		Metadata:     metadata,
+	})
+}
+
+// open opens blob key
+func (e *Engine) open(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	r, info, err := e.b.Get(ctx, key)
+	if err != nil {
+		if xerrors.Is(err, ErrNotExist) {
+			return nil, 0, gotv.ErrFragmentNotFound
+		}
+		return nil, 0, err
+	}
+	return r, info.Size, nil
+}
+
+// validToken reports whether token can be stored under its own prefix
+func validToken(token string) bool {
+	return token != "" && !strings.Contains(token, "/")
+}
+
+// cached returns match of token cached now. Caller must hold the lock.
+// Match returned by load may be replaced by refresh while writing a fragment, so writes update the cached one.
+func (e *Engine) cached(token string, m *match) *match {
+	if c, ok := e.match[token]; ok {
+		return c
+	}
+	return m
+}
+
+// load returns cached match, or rebuilds it from BlobStore if it is not cached or older than cache TTL
+func (e *Engine) load(ctx context.Context, token string) (*match, error) {
+	if !validToken(token) {
+		return nil, gotv.ErrMatchNotFound
+	}
+	now := time.Now()
+	e.RLock()
+	m, ok := e.match[token]
+	fresh := ok && now.Sub(m.loaded) < e.cacheTTL
+	e.RUnlock()
+	if fresh {
+		return m, nil
+	}
+	if !ok && e.isMissing(token) {
+		return nil, gotv.ErrMatchNotFound
+	}
+
+	r, _, err := e.open(ctx, e.matchKey(token))
+	if err != nil {
+		if xerrors.Is(err, gotv.ErrFragmentNotFound) {
+			// deleted by another relay
+			e.Lock()
+			delete(e.match, token)
+			e.Unlock()
+			e.setMissing(token)
+			return nil, gotv.ErrMatchNotFound
+		}
+		return nil, err
+	}
+	defer r.Close()
+	meta := matchMetadata{}
+	if err := json.NewDecoder(r).Decode(&meta); err != nil {
+		return nil, err
+	}
+	m = newMatch(meta, e.delay)
+	m.loaded = now
+
+	type blob struct {
+		fragment int
+		info     Info
+	}
+	var fulls, deltas []blob
+	infos, err := e.b.List(ctx, e.matchPrefix(token), true)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		fragment, field, ok := parseFrameKey(strings.TrimPrefix(info.Key, e.matchPrefix(token)))
+		if !ok {
+			continue
+		}
+		switch field {
+		case "start":
+			if fragment == meta.SignupFragment {
+				m.Start = info.Size
+			}
+		case "full":
+			fulls = append(fulls, blob{fragment: fragment, info: info})
+		case "delta":
+			deltas = append(deltas, blob{fragment: fragment, info: info})
+		}
+	}
+	// keyframe interval is estimated from consecutive full fragments
+	sort.Slice(fulls, func(i, j int) bool { return fulls[i].fragment < fulls[j].fragment })
+	for _, o := range fulls {
+		tick, _ := strconv.Atoi(o.info.Metadata["tick"])
+		m.onFull(o.fragment, tick, parseTime(o.info.Metadata["at"], o.info.Modified), o.info.Size)
+	}
+	for _, o := range deltas {
+		endtick, _ := strconv.Atoi(o.info.Metadata["endtick"])
+		final, _ := strconv.ParseBool(o.info.Metadata["final"])
+		m.onDelta(o.fragment, endtick, final, parseTime(o.info.Metadata["at"], o.info.Modified), o.info.Size)
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	if cached, ok := e.match[token]; ok && !cached.loaded.Before(now) {
+		// loaded or started by another request meanwhile
+		return cached, nil
+	}
+	e.match[token] = m
+	return m, nil
+}
+
+// isMissing reports whether token is known to have no match
+func (e *Engine) isMissing(token string) bool {
+	e.RLock()
+	defer e.RUnlock()
+	expiry, ok := e.missing[token]
+	return ok && time.Now().Before(expiry)
+}
+
+// setMissing remembers token has no match for missingTTL
+func (e *Engine) setMissing(token string) {
+	if e.missingTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	e.Lock()
+	defer e.Unlock()
+	if len(e.missing) >= maxMissing {
+		for t, expiry := range e.missing {
+			if !now.Before(expiry) {
+				delete(e.missing, t)
+			}
+		}
+	}
+	e.missing[token] = now.Add(e.missingTTL)
+}
+
+// parseFrameKey parses "<fragment>_<field>"
+func parseFrameKey(key string) (fragment int, field string, ok bool) {
+	i := strings.LastIndexByte(key, '_')
+	if i < 0 {
+		return 0, "", false
+	}
+	fragment, err := strconv.Atoi(key[:i])
+	if err != nil {
+		return 0, "", false
+	}
+	return fragment, key[i+1:], true
+}
+
+// parseTime parses time in blob metadata, or returns fallback
+func parseTime(s string, fallback time.Time) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return fallback
+	}
+	return t
+}
+
+// checkFrame returns blob key of fragment field if it is stored
+func (e *Engine) checkFrame(ctx context.Context, token string, fragment int, field string) (string, error) {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		if field != "start" && xerrors.Is(err, gotv.ErrMatchNotFound) {
+			return "", gotv.ErrFragmentNotFound
+		}
+		return "", err
+	}
+	e.RLock()
+	defer e.RUnlock()
+	switch field {
+	case "start":
+		if fragment != m.Meta.SignupFragment {
+			return "", gotv.ErrStartExpired
+		}
+	case "full":
+		if f, ok := m.Fragments[fragment]; !ok || !f.HasFull {
+			return "", gotv.ErrFragmentNotFound
+		}
+	case "delta":
+		if f, ok := m.Fragments[fragment]; !ok || !f.HasDelta {
+			return "", gotv.ErrFragmentNotFound
+		}
+	}
+	return e.frameKey(token, fragment, field), nil
+}
+
+// getFrame reads whole fragment field
+func (e *Engine) getFrame(ctx context.Context, token string, fragment int, field string) ([]byte, error) {
+	r, _, err := e.getFrameStream(ctx, token, fragment, field)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// getFrameStream opens fragment field
+func (e *Engine) getFrameStream(ctx context.Context, token string, fragment int, field string) (io.ReadCloser, int64, error) {
+	key, err := e.checkFrame(ctx, token, fragment, field)
+	if err != nil {
+		return nil, 0, err
+	}
+	return e.open(ctx, key)
+}
+
+// Auth implements gotv.StoreV2
+func (e *Engine) Auth(ctx context.Context, token string, auth string) error {
+	if auth != e.password {
+		return gotv.ErrInvalidAuth
+	}
+	return nil
+}
+
+// OnDelta implements gotv.StoreV2
+func (e *Engine) OnDelta(ctx context.Context, token string, fragment int, endtick int, at time.Time, final bool, b []byte) error {
+	return e.OnDeltaStream(ctx, token, fragment, endtick, at, final, bytes.NewReader(b))
+}
+
+// OnDeltaStream implements gotv.StreamStore
+func (e *Engine) OnDeltaStream(ctx context.Context, token string, fragment int, endtick int, at time.Time, final bool, r io.Reader) error {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		return err
+	}
+	n, err := e.putFrame(ctx, e.frameKey(token, fragment, "delta"), map[string]string{
+		"endtick": strconv.Itoa(endtick),
+		"final":   strconv.FormatBool(final),
+		"at":      at.Format(time.RFC3339Nano),
+	}, r)
+	if err != nil {
+		return err
+	}
+	e.Lock()
+	defer e.Unlock()
+	e.cached(token, m).onDelta(fragment, endtick, final, at, n)
+	return nil
+}
+
+// OnFull implements gotv.StoreV2
+func (e *Engine) OnFull(ctx context.Context, token string, fragment int, tick int, at time.Time, b []byte) error {
+	return e.OnFullStream(ctx, token, fragment, tick, at, bytes.NewReader(b))
+}
+
+// OnFullStream implements gotv.StreamStore
+func (e *Engine) OnFullStream(ctx context.Context, token string, fragment int, tick int, at time.Time, r io.Reader) error {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		return err
+	}
+	n, err := e.putFrame(ctx, e.frameKey(token, fragment, "full"), map[string]string{
+		"tick": strconv.Itoa(tick),
+		"at":   at.Format(time.RFC3339Nano),
+	}, r)
+	if err != nil {
+		return err
+	}
+	e.Lock()
+	defer e.Unlock()
+	e.cached(token, m).onFull(fragment, tick, at, n)
+	return nil
+}
+
+// OnStart implements gotv.StoreV2
+func (e *Engine) OnStart(ctx context.Context, token string, fragment int, f gotv.StartFrame) error {
+	return e.OnStartStream(ctx, token, fragment, f, bytes.NewReader(f.Body))
+}
+
+// OnStartStream implements gotv.StreamStore
+// Token containing "/" is ErrInvalidToken.
+func (e *Engine) OnStartStream(ctx context.Context, token string, fragment int, f gotv.StartFrame, r io.Reader) error {
+	if !validToken(token) {
+		return ErrInvalidToken
+	}
+	n, err := e.putFrame(ctx, e.frameKey(token, fragment, "start"), nil, r)
+	if err != nil {
+		return err
+	}
+	meta := matchMetadata{
+		SignupFragment:   fragment,
+		At:               f.At,
+		TickPerSecond:    f.Tps,
+		KeyframeInterval: f.KeyframeInterval,
+		Map:              f.Map,
+		Protocol:         f.Protocol,
+	}
+	// delay override is kept over new signup fragment
+	e.RLock()
+	if m, ok := e.match[token]; ok {
+		meta.Delay = m.Meta.Delay
+	}
+	e.RUnlock()
+	if err := e.putMatchMetadata(ctx, token, meta); err != nil {
+		return err
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	delete(e.missing, token)
+	m, ok := e.match[token]
+	if !ok {
+		m = newMatch(meta, e.delay)
+		m.loaded = time.Now()
+		e.match[token] = m
+	}
+	m.Meta = meta
+	m.KeyframeInterval = meta.KeyframeInterval
+	m.Start = n
+	if f.At.After(m.ReceivedAt) {
+		m.ReceivedAt = f.At
+	}
+	return nil
+}
+
+// putMatchMetadata writes match.json
+func (e *Engine) putMatchMetadata(ctx context.Context, token string, meta matchMetadata) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	_, err = e.b.Put(ctx, e.matchKey(token), bytes.NewReader(b), Attrs{
+		ContentType:  "application/json",
+		CacheControl: "no-cache",
+		StorageClass: e.storageClass,
+	})
+	return err
+}
+
+// GetMatchInfo implements gotv.MatchInfoBroadcaster
+func (e *Engine) GetMatchInfo(ctx context.Context, token string) (gotv.MatchInfo, error) {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		return gotv.MatchInfo{}, err
+	}
+	e.RLock()
+	defer e.RUnlock()
+	return m.MatchInfo(), nil
+}
+
+// GetMatchDelay implements gotv.DelayBroadcaster
+func (e *Engine) GetMatchDelay(ctx context.Context, token string) (time.Duration, error) {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	e.RLock()
+	defer e.RUnlock()
+	return m.Delay, nil
+}
+
+// SetMatchDelay implements gotv.DelayBroadcaster. Override is kept in match.json, so other relays load it with the match.
+// Relays which already cached the match apply it once their cache TTL expires.
+func (e *Engine) SetMatchDelay(ctx context.Context, token string, d time.Duration) error {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		return err
+	}
+	ms := d.Milliseconds()
+	e.RLock()
+	meta := m.Meta
+	e.RUnlock()
+	meta.Delay = &ms
+	if err := e.putMatchMetadata(ctx, token, meta); err != nil {
+		return err
+	}
+	e.Lock()
+	defer e.Unlock()
+	m = e.cached(token, m)
+	m.Meta.Delay = &ms
+	m.Delay = d
+	return nil
+}
+
+// GetDelta implements gotv.BroadcasterV2
+func (e *Engine) GetDelta(ctx context.Context, token string, fragment int) ([]byte, error) {
+	return e.getFrame(ctx, token, fragment, "delta")
+}
+
+// GetFull implements gotv.BroadcasterV2
+func (e *Engine) GetFull(ctx context.Context, token string, fragment int) ([]byte, error) {
+	return e.getFrame(ctx, token, fragment, "full")
+}
+
+// GetStart implements gotv.BroadcasterV2
+func (e *Engine) GetStart(ctx context.Context, token string, fragment int) ([]byte, error) {
+	return e.getFrame(ctx, token, fragment, "start")
+}
+
+// GetDeltaStream implements gotv.StreamBroadcaster
+func (e *Engine) GetDeltaStream(ctx context.Context, token string, fragment int) (io.ReadCloser, int64, error) {
+	return e.getFrameStream(ctx, token, fragment, "delta")
+}
+
+// GetFullStream implements gotv.StreamBroadcaster
+func (e *Engine) GetFullStream(ctx context.Context, token string, fragment int) (io.ReadCloser, int64, error) {
+	return e.getFrameStream(ctx, token, fragment, "full")
+}
+
+// GetStartStream implements gotv.StreamBroadcaster
+func (e *Engine) GetStartStream(ctx context.Context, token string, fragment int) (io.ReadCloser, int64, error) {
+	return e.getFrameStream(ctx, token, fragment, "start")
+}
+
+// GetRedirectURL implements gotv.RedirectBroadcaster. Fragments are served through relay unless WithRedirect is set.
+func (e *Engine) GetRedirectURL(ctx context.Context, token string, fragment int, field string) (string, error) {
+	if e.redirect == "" {
+		return "", nil
+	}
+	key, err := e.checkFrame(ctx, token, fragment, field)
+	if err != nil {
+		return "", err
+	}
+	return e.redirect + "/" + (&url.URL{Path: key}).EscapedPath(), nil
+}
+
+// GetSync implements gotv.BroadcasterV2
+func (e *Engine) GetSync(ctx context.Context, token string, fragment int) (gotv.Sync, error) {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		return gotv.Sync{}, err
+	}
+	e.RLock()
+	defer e.RUnlock()
+	return gotv.SelectSync(m, fragment, time.Now())
+}
+
+// GetSyncLatest implements gotv.BroadcasterV2
+func (e *Engine) GetSyncLatest(ctx context.Context, token string) (gotv.Sync, error) {
+	return e.GetSync(ctx, token, 0)
+}
+
+// GetFragmentMetadata implements gotv.FragmentMetadataBroadcaster
+func (e *Engine) GetFragmentMetadata(ctx context.Context, token string, fragment int) (gotv.FragmentMetadata, error) {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		return gotv.FragmentMetadata{}, err
+	}
+	e.RLock()
+	defer e.RUnlock()
+	f, ok := m.Fragments[fragment]
+	if !ok {
+		return gotv.FragmentMetadata{}, gotv.ErrFragmentNotFound
+	}
+	return gotv.FragmentMetadata{
+		Tick:      f.Tick,
+		EndTick:   f.EndTick,
+		Final:     f.Final,
+		Timestamp: f.At.UnixMilli(),
+		Full:      int(f.Full),
+		Delta:     int(f.Delta),
+	}, nil
+}
+
+// GetMatchSize implements gotv.SizeBroadcaster
+func (e *Engine) GetMatchSize(ctx context.Context, token string) (gotv.MatchSize, error) {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		return gotv.MatchSize{}, err
+	}
+	e.RLock()
+	defer e.RUnlock()
+	size := gotv.MatchSize{
+		Bytes:     m.Start,
+		Fragments: len(m.Fragments),
+	}
+	for _, f := range m.Fragments {
+		size.Bytes += f.Full + f.Delta
+	}
+	return size, nil
+}
+
+// DeleteMatch implements gotv.Deleter
+func (e *Engine) DeleteMatch(ctx context.Context, token string) error {
+	if _, err := e.load(ctx, token); err != nil {
+		return err
+	}
+	e.Lock()
+	delete(e.match, token)
+	e.Unlock()
+	infos, err := e.b.List(ctx, e.matchPrefix(token), false)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := e.b.Delete(ctx, info.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteFragment implements gotv.Deleter
+func (e *Engine) DeleteFragment(ctx context.Context, token string, fragment int) error {
+	m, err := e.load(ctx, token)
+	if err != nil {
+		return err
+	}
+	e.RLock()
+	_, ok := m.Fragments[fragment]
+	e.RUnlock()
+	if !ok {
+		return gotv.ErrFragmentNotFound
+	}
+	for _, field := range []string{"full", "delta"} {
+		if err := e.b.Delete(ctx, e.frameKey(token, fragment, field)); err != nil {
+			return err
+		}
+	}
+	e.Lock()
+	defer e.Unlock()
+	delete(e.cached(token, m).Fragments, fragment)
+	return nil
+}
+
+// New Get new pointer of BlobStore GOTV+ Engine
+func New(b BlobStore, password string, delay time.Duration, opts ...Option) *Engine {
+	e := &Engine{
+		RWMutex:    sync.RWMutex{},
+		b:          b,
+		password:   password,
+		delay:      delay,
+		match:      map[string]*match{},
+		cacheTTL:   2 * time.Second,
+		missing:    map[string]time.Time{},
+		missingTTL: 2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
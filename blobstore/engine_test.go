@@ -0,0 +1,313 @@
+package blobstore_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/blobstore"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+// memory BlobStore in memory
+type memory struct {
+	sync.Mutex
+	blobs map[string]*blob
+	gets  int // number of Get calls
+}
+
+type blob struct {
+	attrs    blobstore.Attrs
+	data     []byte
+	modified time.Time
+}
+
+func (m *memory) Put(ctx context.Context, key string, r io.Reader, attrs blobstore.Attrs) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.blobs[key] = &blob{attrs: attrs, data: b, modified: time.Now()}
+	return int64(len(b)), nil
+}
+
+func (m *memory) Get(ctx context.Context, key string) (io.ReadCloser, blobstore.Info, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.gets++
+	b, ok := m.blobs[key]
+	if !ok {
+		return nil, blobstore.Info{}, blobstore.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(b.data)), blobstore.Info{Key: key, Size: int64(len(b.data)), Modified: b.modified}, nil
+}
+
+func (m *memory) Delete(ctx context.Context, key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.blobs, key)
+	return nil
+}
+
+func (m *memory) List(ctx context.Context, prefix string, metadata bool) ([]blobstore.Info, error) {
+	m.Lock()
+	defer m.Unlock()
+	infos := []blobstore.Info{}
+	for key, b := range m.blobs {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, blobstore.Info{Key: key, Size: int64(len(b.data)), Modified: b.modified, Metadata: b.attrs.Metadata})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func newMemory() *memory {
+	return &memory{blobs: map[string]*blob{}}
+}
+
+func TestEngine(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	m := newMemory()
+	e := blobstore.New(m, "gopher", 30*time.Second, blobstore.WithPrefix("gotv/"), blobstore.WithStorageClass("STANDARD"), blobstore.WithCacheControl("public, max-age=31536000, immutable"))
+
+	asserts.ErrorIs(e.Auth(ctx, "match", "invalid"), gotv.ErrInvalidAuth)
+	asserts.NoError(e.Auth(ctx, "match", "gopher"))
+
+	now := time.Now()
+	asserts.ErrorIs(e.OnFull(ctx, "match", 1, 384, now, []byte("full")), gotv.ErrMatchNotFound)
+	asserts.NoError(e.OnStart(ctx, "match", 1, gotv.StartFrame{At: now, Tps: 128, Map: "de_dust2", Protocol: 4, Body: []byte("start")}))
+	for i := 1; i <= 20; i++ {
+		// fragment 20 is received now, fragment 1 is received 57 seconds ago
+		at := now.Add(-time.Duration(20-i) * 3 * time.Second)
+		asserts.NoError(e.OnFull(ctx, "match", i, i*384, at, []byte("full")))
+		asserts.NoError(e.OnDelta(ctx, "match", i, (i+1)*384, at, i == 20, []byte("delta")))
+	}
+
+	// fragments are cacheable, and match.json is not
+	m.Lock()
+	asserts.Equal(blobstore.Attrs{
+		ContentType:  "application/octet-stream",
+		CacheControl: "public, max-age=31536000, immutable",
+		StorageClass: "STANDARD",
+		Metadata:     map[string]string{"tick": "384", "at": now.Add(-57 * time.Second).Format(time.RFC3339Nano)},
+	}, m.blobs["gotv/match/1_full"].attrs)
+	asserts.Equal("no-cache", m.blobs["gotv/match/match.json"].attrs.CacheControl)
+	m.Unlock()
+
+	for _, td := range []struct {
+		title    string
+		get      func() ([]byte, error)
+		expected []byte
+		err      error
+	}{
+		{title: "start", get: func() ([]byte, error) { return e.GetStart(ctx, "match", 1) }, expected: []byte("start")},
+		{title: "expired start", get: func() ([]byte, error) { return e.GetStart(ctx, "match", 2) }, err: gotv.ErrStartExpired},
+		{title: "full", get: func() ([]byte, error) { return e.GetFull(ctx, "match", 3) }, expected: []byte("full")},
+		{title: "delta", get: func() ([]byte, error) { return e.GetDelta(ctx, "match", 3) }, expected: []byte("delta")},
+		{title: "not received", get: func() ([]byte, error) { return e.GetDelta(ctx, "match", 21) }, err: gotv.ErrFragmentNotFound},
+		{title: "unknown match", get: func() ([]byte, error) { return e.GetFull(ctx, "unknown", 1) }, err: gotv.ErrFragmentNotFound},
+	} {
+		t.Run(td.title, func(t *testing.T) {
+			asserts := assert.New(t)
+			b, err := td.get()
+			if td.err != nil {
+				asserts.ErrorIs(err, td.err)
+				return
+			}
+			asserts.NoError(err)
+			asserts.Equal(td.expected, b)
+		})
+	}
+
+	// delay is honored
+	s, err := e.GetSyncLatest(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(10, s.Fragment)
+	asserts.Equal(3.0, s.KeyframeInterval)
+	s, err = e.GetSync(ctx, "match", 15)
+	asserts.NoError(err)
+	asserts.Equal(10, s.Fragment)
+
+	metadata, err := e.GetFragmentMetadata(ctx, "match", 20)
+	asserts.NoError(err)
+	asserts.Equal(gotv.FragmentMetadata{Tick: 20 * 384, EndTick: 21 * 384, Final: true, Timestamp: now.UnixMilli(), Full: 4, Delta: 5}, metadata)
+
+	// another relay rebuilds sync state from BlobStore
+	reloaded := blobstore.New(m, "gopher", 30*time.Second, blobstore.WithPrefix("gotv/"))
+	s, err = reloaded.GetSyncLatest(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(10, s.Fragment)
+	asserts.Equal(10*384, s.Tick)
+	asserts.Equal("de_dust2", s.Map)
+	asserts.Equal(3.0, s.KeyframeInterval)
+	size, err := reloaded.GetMatchSize(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(gotv.MatchSize{Bytes: 5 + 20*9, Fragments: 20}, size)
+	metadata, err = reloaded.GetFragmentMetadata(ctx, "match", 20)
+	asserts.NoError(err)
+	asserts.True(metadata.Final)
+
+	asserts.NoError(e.DeleteFragment(ctx, "match", 20))
+	_, err = e.GetFull(ctx, "match", 20)
+	asserts.ErrorIs(err, gotv.ErrFragmentNotFound)
+	asserts.ErrorIs(e.DeleteFragment(ctx, "match", 20), gotv.ErrFragmentNotFound)
+	asserts.NoError(e.DeleteMatch(ctx, "match"))
+	_, err = blobstore.New(m, "gopher", 0, blobstore.WithPrefix("gotv/")).GetSyncLatest(ctx, "match")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+	m.Lock()
+	asserts.Empty(m.blobs)
+	m.Unlock()
+}
+
+func TestEngineRedirect(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	e := blobstore.New(newMemory(), "gopher", 0, blobstore.WithRedirect("https://cdn.example.com/"))
+	asserts.NoError(e.OnStart(ctx, "match", 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")}))
+	asserts.NoError(e.OnFull(ctx, "match", 1, 384, time.Now(), []byte("full")))
+
+	h := gotv.NewHTTPHandler(e, e)
+	for _, td := range []struct {
+		title    string
+		path     string
+		status   int
+		location string
+	}{
+		{title: "start", path: "/match/1/start", status: http.StatusFound, location: "https://cdn.example.com/match/1_start"},
+		{title: "full", path: "/match/1/full", status: http.StatusFound, location: "https://cdn.example.com/match/1_full"},
+		{title: "delta not received", path: "/match/1/delta", status: http.StatusNotFound},
+		{title: "expired start", path: "/match/2/start", status: http.StatusNotFound},
+	} {
+		t.Run(td.title, func(t *testing.T) {
+			asserts := assert.New(t)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, td.path, nil))
+			asserts.Equal(td.status, rec.Code)
+			asserts.Equal(td.location, rec.Header().Get("Location"))
+		})
+	}
+
+	// delayed match is served through relay
+	delayed := blobstore.New(newMemory(), "gopher", 0, blobstore.WithRedirect("https://cdn.example.com/"))
+	asserts.NoError(delayed.OnStart(ctx, "match", 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")}))
+	asserts.NoError(delayed.OnFull(ctx, "match", 1, 384, time.Now().Add(-time.Minute), []byte("full")))
+	asserts.NoError(delayed.SetMatchDelay(ctx, "match", 30*time.Second))
+	h = gotv.NewHTTPHandler(delayed, delayed)
+	for _, path := range []string{"/match/1/start", "/match/1/full"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		asserts.Equal(http.StatusOK, rec.Code, path)
+		asserts.Empty(rec.Header().Get("Location"), path)
+	}
+}
+
+func TestEngineNegativeCache(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	mem := newMemory()
+	e := blobstore.New(mem, "gopher", 0, blobstore.WithNegativeCacheTTL(time.Hour))
+	other := blobstore.New(mem, "gopher", 0)
+
+	// unknown token is looked up once
+	for i := 0; i < 3; i++ {
+		_, err := e.GetSyncLatest(ctx, "match")
+		asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+	}
+	asserts.Equal(1, mem.gets)
+
+	// match started on another relay is not found until the entry expires
+	asserts.NoError(other.OnStart(ctx, "match", 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")}))
+	_, err := e.GetSyncLatest(ctx, "match")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+
+	// start on this relay clears the entry
+	asserts.NoError(e.OnStart(ctx, "match", 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")}))
+	b, err := e.GetStart(ctx, "match", 1)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start"), b)
+
+	// disabled
+	e = blobstore.New(mem, "gopher", 0, blobstore.WithNegativeCacheTTL(0))
+	gets := mem.gets
+	for i := 0; i < 3; i++ {
+		_, err := e.GetSyncLatest(ctx, "unknown")
+		asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+	}
+	asserts.Equal(gets+3, mem.gets)
+}
+
+func TestEngineShared(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	mem := newMemory()
+	now := time.Now()
+	ingest := blobstore.New(mem, "gopher", 0)
+	serve := blobstore.New(mem, "gopher", 0, blobstore.WithCacheTTL(0), blobstore.WithNegativeCacheTTL(0))
+	frozen := blobstore.New(mem, "gopher", 0, blobstore.WithCacheTTL(time.Hour))
+	send := func(from, to int) {
+		for i := from; i <= to; i++ {
+			asserts.NoError(ingest.OnFull(ctx, "match", i, i*384, now, []byte("full")))
+			asserts.NoError(ingest.OnDelta(ctx, "match", i, (i+1)*384, now, false, []byte("delta")))
+		}
+	}
+
+	// match started later on another relay is found
+	_, err := serve.GetMatchInfo(ctx, "match")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+	asserts.NoError(ingest.OnStart(ctx, "match", 1, gotv.StartFrame{At: now, Tps: 128, KeyframeInterval: 3, Map: "de_dust2", Body: []byte("start")}))
+	send(1, 10)
+	info, err := serve.GetMatchInfo(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(10, info.Latest)
+	info, err = frozen.GetMatchInfo(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(10, info.Latest)
+
+	// new fragments and signup fragment are served once cache TTL expires
+	send(11, 12)
+	asserts.NoError(ingest.OnStart(ctx, "match", 13, gotv.StartFrame{At: now, Tps: 128, KeyframeInterval: 3, Map: "de_inferno", Body: []byte("start2")}))
+	send(13, 14)
+	info, err = serve.GetMatchInfo(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(14, info.Latest)
+	asserts.Equal(13, info.SignupFragment)
+	asserts.Equal("de_inferno", info.Map)
+	b, err := serve.GetStart(ctx, "match", 13)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start2"), b)
+	info, err = frozen.GetMatchInfo(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(10, info.Latest)
+	asserts.Equal(1, info.SignupFragment)
+
+	// match deleted on another relay
+	asserts.NoError(ingest.DeleteMatch(ctx, "match"))
+	_, err = serve.GetMatchInfo(ctx, "match")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+}
+
+func TestEngineInvalidToken(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	mem := newMemory()
+	e := blobstore.New(mem, "gopher", 0)
+	for _, token := range []string{"a/b", "/match", ""} {
+		asserts.ErrorIs(e.OnStart(ctx, token, 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")}), blobstore.ErrInvalidToken, token)
+		_, err := e.GetSyncLatest(ctx, token)
+		asserts.ErrorIs(err, gotv.ErrMatchNotFound, token)
+	}
+	asserts.Empty(mem.blobs)
+}
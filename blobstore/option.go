@@ -0,0 +1,57 @@
+package blobstore
+
+import (
+	"strings"
+	"time"
+)
+
+// Option Engine option
+type Option func(e *Engine)
+
+// WithPrefix stores every blob under prefix, e.g. "gotv/"
+func WithPrefix(prefix string) Option {
+	return func(e *Engine) {
+		e.prefix = prefix
+	}
+}
+
+// WithStorageClass stores blobs in storageClass, e.g. "STANDARD". Bucket default is used if empty.
+func WithStorageClass(storageClass string) Option {
+	return func(e *Engine) {
+		e.storageClass = storageClass
+	}
+}
+
+// WithCacheControl sets Cache-Control of start, full and delta fragments, e.g. "public, max-age=31536000, immutable".
+// match.json is always stored with "no-cache" because it is overwritten on every start.
+func WithCacheControl(cacheControl string) Option {
+	return func(e *Engine) {
+		e.cacheControl = cacheControl
+	}
+}
+
+// WithRedirect redirects clients to baseURL + "/" + blob key instead of serving fragments through relay,
+// e.g. public URL of the bucket or URL of CDN in front of the bucket.
+// Matches with broadcast delay are always served through relay, because clients could fetch fragments within the delay from the bucket.
+func WithRedirect(baseURL string) Option {
+	return func(e *Engine) {
+		e.redirect = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithCacheTTL rebuilds cached sync state of a match from BlobStore once it is older than d,
+// so new fragments and signup fragments written by another relay are served after d at most.
+// Each rebuild lists every fragment of the match. 0 rebuilds on every request. Default is 2 seconds.
+func WithCacheTTL(d time.Duration) Option {
+	return func(e *Engine) {
+		e.cacheTTL = d
+	}
+}
+
+// WithNegativeCacheTTL remembers tokens without match for d, so requests for unknown token don't hit BlobStore every time.
+// Match started on another relay is found after d at most. 0 disables it. Default is 2 seconds.
+func WithNegativeCacheTTL(d time.Duration) Option {
+	return func(e *Engine) {
+		e.missingTTL = d
+	}
+}
@@ -0,0 +1,217 @@
+package demo
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"golang.org/x/xerrors"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+//
+// Exports a stored GOTV+ match into .dem file which plays in the game's demo player.
+//
+// Broadcast fragments are already sequences of demo commands, so the file is:
+//
+//	header            HL2DEMO header, see Header
+//	start fragment    signon data
+//	full fragment     keyframe of the first exported fragment
+//	delta fragments   from the first exported fragment until the final or the latest fragment
+//	dem_stop          end of demo
+//
+
+// DemoProtocol demo file protocol of HL2DEMO header
+const DemoProtocol = 4
+
+// HeaderSize size of HL2DEMO header in bytes
+const HeaderSize = 8 + 4 + 4 + 4*maxPath + 4 + 4 + 4 + 4
+
+const (
+	filestamp = "HL2DEMO\x00"
+	maxPath   = 260
+	demStop   = 7 // dem_stop command
+)
+
+// ErrNoFragment the match has no fragment to export
+var ErrNoFragment = xerrors.New("No Fragment To Export")
+
+// ErrMissingFragment is gotv.ErrMissingFragment, kept for callers of Export
+var ErrMissingFragment = gotv.ErrMissingFragment
+
+// Header HL2DEMO header
+type Header struct {
+	NetworkProtocol int
+	ServerName      string
+	ClientName      string
+	MapName         string
+	GameDirectory   string
+	PlaybackTime    float32 // seconds
+	PlaybackTicks   int
+	PlaybackFrames  int // 0 as fragments are not parsed to count frames
+	SignonLength    int // bytes of signon data following the header
+}
+
+// MarshalBinary encodes h in HL2DEMO layout. Strings longer than 259 bytes are truncated.
+func (h Header) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, HeaderSize)
+	b = append(b, filestamp...)
+	b = appendInt32(b, DemoProtocol)
+	b = appendInt32(b, h.NetworkProtocol)
+	for _, s := range []string{h.ServerName, h.ClientName, h.MapName, h.GameDirectory} {
+		b = appendString(b, s)
+	}
+	b = appendInt32(b, int(math.Float32bits(h.PlaybackTime)))
+	b = appendInt32(b, h.PlaybackTicks)
+	b = appendInt32(b, h.PlaybackFrames)
+	b = appendInt32(b, h.SignonLength)
+	return b, nil
+}
+
+func appendInt32(b []byte, v int) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(v))
+	return append(b, buf[:]...)
+}
+
+// appendString appends s as NUL-padded fixed-size string
+func appendString(b []byte, s string) []byte {
+	if len(s) > maxPath-1 {
+		s = s[:maxPath-1]
+	}
+	b = append(b, s...)
+	return append(b, make([]byte, maxPath-len(s))...)
+}
+
+// Exporter writes stored matches into .dem files
+type Exporter struct {
+	b             gotv.BroadcasterV2
+	serverName    string
+	clientName    string
+	gameDirectory string
+}
+
+// Export writes match of token into w, and returns written header.
+// Export starts from the first fragment after the current signup fragment which has full fragment,
+// and ends at the final fragment or the latest fragment. Delta fragment missing before that is ErrMissingFragment.
+// Header is written last, so w must be seekable e.g. *os.File.
+func (e *Exporter) Export(ctx context.Context, w io.WriteSeeker, token string) (Header, error) {
+	info, err := gotv.ReadRecording(ctx, e.b, token)
+	if xerrors.Is(err, gotv.ErrFragmentNotFound) {
+		return Header{}, ErrNoFragment
+	}
+	if err != nil {
+		return Header{}, err
+	}
+	first, startTick := info.First, info.FirstTick
+	start, err := e.b.GetStart(ctx, token, info.SignupFragment)
+	if err != nil {
+		return Header{}, err
+	}
+	full, err := e.b.GetFull(ctx, token, first)
+	if err != nil {
+		return Header{}, err
+	}
+
+	origin, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Header{}, err
+	}
+	// placeholder until playback length is known
+	if _, err := w.Write(make([]byte, HeaderSize)); err != nil {
+		return Header{}, err
+	}
+	if _, err := w.Write(start); err != nil {
+		return Header{}, err
+	}
+	if _, err := w.Write(full); err != nil {
+		return Header{}, err
+	}
+
+	// fragments are keyframe interval apart, used if b does not serve fragment metadata
+	ticks := int(math.Round(info.KeyframeInterval * info.TickPerSecond))
+	endTick := startTick
+	for fragment := first; info.Latest == 0 || fragment <= info.Latest; fragment++ {
+		if err := ctx.Err(); err != nil {
+			return Header{}, err
+		}
+		delta, err := e.b.GetDelta(ctx, token, fragment)
+		if xerrors.Is(err, gotv.ErrFragmentNotFound) {
+			if info.Latest == 0 && fragment > first {
+				// latest fragment is unknown without gotv.MatchInfoBroadcaster
+				break
+			}
+			return Header{}, xerrors.Errorf("delta fragment %d: %w", fragment, ErrMissingFragment)
+		}
+		if err != nil {
+			return Header{}, err
+		}
+		if _, err := w.Write(delta); err != nil {
+			return Header{}, err
+		}
+		m, ok := gotv.GetFragmentMetadataOf(ctx, e.b, token, fragment)
+		if !ok {
+			endTick += ticks
+			continue
+		}
+		endTick = m.EndTick
+		if m.Final {
+			break
+		}
+	}
+	if endTick <= startTick {
+		return Header{}, ErrNoFragment
+	}
+
+	stop := appendInt32([]byte{demStop}, endTick)
+	if _, err := w.Write(append(stop, 0)); err != nil {
+		return Header{}, err
+	}
+	end, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Header{}, err
+	}
+
+	h := Header{
+		NetworkProtocol: info.Protocol,
+		ServerName:      e.serverName,
+		ClientName:      e.clientName,
+		MapName:         info.Map,
+		GameDirectory:   e.gameDirectory,
+		PlaybackTicks:   endTick - startTick,
+		SignonLength:    len(start),
+	}
+	if info.TickPerSecond > 0 {
+		h.PlaybackTime = float32(float64(h.PlaybackTicks) / info.TickPerSecond)
+	}
+	b, err := h.MarshalBinary()
+	if err != nil {
+		return Header{}, err
+	}
+	if _, err := w.Seek(origin, io.SeekStart); err != nil {
+		return Header{}, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return Header{}, err
+	}
+	if _, err := w.Seek(end, io.SeekStart); err != nil {
+		return Header{}, err
+	}
+	return h, nil
+}
+
+// New Get new pointer of Exporter which reads matches from b
+func New(b gotv.BroadcasterV2, opts ...Option) *Exporter {
+	e := &Exporter{
+		b:             b,
+		serverName:    "GOTV+",
+		clientName:    "GOTV Demo",
+		gameDirectory: "csgo",
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
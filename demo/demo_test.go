@@ -0,0 +1,73 @@
+package demo_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/demo"
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+func TestExport(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	// delay does not hide stored fragments from export
+	m := inmemory.NewInmemoryGOTV("gopher", inmemory.WithDelay(time.Hour))
+	defer m.Close()
+	asserts.NoError(m.OnStart("match", 2, gotv.StartFrame{At: time.Now(), Tps: 128, KeyframeInterval: 3, Protocol: 4, Map: "de_dust2", Body: []byte("start")}))
+	for i := 2; i <= 4; i++ {
+		asserts.NoError(m.OnFull("match", i, i*384, time.Now(), []byte{'f', byte('0' + i)}))
+		asserts.NoError(m.OnDelta("match", i, (i+1)*384, time.Now(), i == 4, []byte{'d', byte('0' + i)}))
+	}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "match.dem"))
+	asserts.NoError(err)
+	defer f.Close()
+	h, err := demo.New(gotv.WrapBroadcaster(m), demo.WithServerName("gopher")).Export(ctx, f, "match")
+	asserts.NoError(err)
+	asserts.Equal(demo.Header{
+		NetworkProtocol: 4,
+		ServerName:      "gopher",
+		ClientName:      "GOTV Demo",
+		MapName:         "de_dust2",
+		GameDirectory:   "csgo",
+		PlaybackTime:    9,
+		PlaybackTicks:   1152,
+		SignonLength:    5,
+	}, h)
+
+	b, err := os.ReadFile(f.Name())
+	asserts.NoError(err)
+	asserts.Len(b, demo.HeaderSize+len("start")+2+3*2+6)
+	asserts.Equal([]byte("HL2DEMO\x00"), b[:8])
+	asserts.Equal(uint32(demo.DemoProtocol), binary.LittleEndian.Uint32(b[8:]))
+	asserts.Equal(uint32(4), binary.LittleEndian.Uint32(b[12:]))
+	asserts.Equal("gopher", string(bytes.TrimRight(b[16:16+260], "\x00")))
+	asserts.Equal("de_dust2", string(bytes.TrimRight(b[16+260*2:16+260*3], "\x00")))
+	asserts.Equal(float32(9), math.Float32frombits(binary.LittleEndian.Uint32(b[16+260*4:])))
+	asserts.Equal(uint32(1152), binary.LittleEndian.Uint32(b[16+260*4+4:]))
+	asserts.Equal(uint32(5), binary.LittleEndian.Uint32(b[16+260*4+12:]))
+
+	body := b[demo.HeaderSize:]
+	asserts.Equal("startf2d2d3d4", string(body[:len(body)-6]))
+	// dem_stop at the last tick
+	asserts.Equal([]byte{7}, body[len(body)-6:len(body)-5])
+	asserts.Equal(uint32(5*384), binary.LittleEndian.Uint32(body[len(body)-5:]))
+
+	_, err = demo.New(gotv.WrapBroadcaster(m)).Export(ctx, f, "unknown")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+
+	// hole before the final fragment is not exported as complete demo
+	asserts.NoError(m.DeleteFragment(ctx, "match", 3))
+	_, err = demo.New(gotv.WrapBroadcaster(m)).Export(ctx, f, "match")
+	asserts.ErrorIs(err, demo.ErrMissingFragment)
+}
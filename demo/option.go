@@ -0,0 +1,25 @@
+package demo
+
+// Option Exporter option
+type Option func(e *Exporter)
+
+// WithServerName sets server name of demo header. Default is "GOTV+".
+func WithServerName(name string) Option {
+	return func(e *Exporter) {
+		e.serverName = name
+	}
+}
+
+// WithClientName sets client name of demo header. Default is "GOTV Demo".
+func WithClientName(name string) Option {
+	return func(e *Exporter) {
+		e.clientName = name
+	}
+}
+
+// WithGameDirectory sets game directory of demo header. Default is "csgo".
+func WithGameDirectory(dir string) Option {
+	return func(e *Exporter) {
+		e.gameDirectory = dir
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/FlowingSPDG/gotv-plus-go/demo"
+	"github.com/FlowingSPDG/gotv-plus-go/examples/disk"
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+var (
+	dir      string
+	snapshot string
+	token    string
+	out      string
+	server   string
+)
+
+func main() {
+	flag.StringVar(&dir, "dir", "", "Directory of Disk engine to read the match from")
+	flag.StringVar(&snapshot, "snapshot", "", "Snapshot file of InMemory engine to read the match from. Used if -dir is empty")
+	flag.StringVar(&token, "token", "", "Token of the match to export")
+	flag.StringVar(&out, "out", "", "Path of .dem file. \"<token>.dem\" if empty")
+	flag.StringVar(&server, "server", "GOTV+", "Server name of demo header")
+	flag.Parse()
+	if token == "" {
+		log.Fatalln("-token is required")
+	}
+	if out == "" {
+		out = token + ".dem"
+	}
+
+	var b gotv.BroadcasterV2
+	switch {
+	case dir != "":
+		d, err := disk.NewDiskGOTV("", dir)
+		if err != nil {
+			panic(err)
+		}
+		defer d.Close()
+		b = gotv.WrapBroadcaster(d)
+	case snapshot != "":
+		m := inmemory.NewInmemoryGOTV("", inmemory.WithSnapshotFile(snapshot))
+		defer m.Close()
+		if err := m.Load(); err != nil {
+			panic(err)
+		}
+		b = gotv.WrapBroadcaster(m)
+	default:
+		log.Fatalln("-dir or -snapshot is required")
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	h, err := demo.New(b, demo.WithServerName(server)).Export(context.Background(), f, token)
+	if err != nil {
+		f.Close()
+		os.Remove(out)
+		log.Fatalln("Failed to export", token, ":", err)
+	}
+	log.Printf("Exported %s to %s: map %s, %d ticks (%.0fs)\n", token, out, h.MapName, h.PlaybackTicks, h.PlaybackTime)
+}
@@ -12,12 +12,15 @@ import (
 )
 
 func main() {
-	m := disk.NewDiskGOTV("SuperSecureStringDoNotShare", "gotv_plus_binary")
+	m, err := disk.NewDiskGOTV("SuperSecureStringDoNotShare", "gotv_plus_binary")
+	if err != nil {
+		panic(err)
+	}
 	app := fiber.New()
 	g := app.Group("/gotv") // /gotv
 	g.Use(logger.New())
-	gotv.SetupStoreHandlersFiber(m, g)
-	gotv.SetupBroadcasterHandlersFiber(m, g)
+	gotv.SetupStoreHandlersFiber(gotv.WrapStore(m), g)
+	gotv.SetupBroadcasterHandlersFiber(gotv.WrapBroadcaster(m), g)
 
 	p := net.JoinHostPort("localhost", "8080")
 
@@ -1,10 +1,14 @@
 package disk
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/FlowingSPDG/gotv-plus-go/gotv"
@@ -14,164 +18,472 @@ import (
 //
 // Disk based GOTV+ Engine example
 //
-// This example does not handle any delay, caching, or hidden option features.
-// It only gives you fragment client requested.
+// Each match is stored in its own directory under dir:
+//
+//	<dir>/<token>/index.jsonl            append-only index of fragments
+//	<dir>/<token>/<fragment>_start.bin   start fragment
+//	<dir>/<token>/<fragment>_full.bin    full fragment
+//	<dir>/<token>/<fragment>_delta.bin   delta fragment
+//
+// Fragment files are written to temporary file and renamed, then index entry is appended.
+// Index is cached in memory, so /sync does not touch the disk.
+// NewDiskGOTV loads every index under dir, so matches resume after restart.
+//
+// Disk does not use blobstore.Engine. The index is appended after each fragment is renamed into place,
+// so a crash never leaves a half-written fragment or a lost match.json, and restart does not list every fragment file.
 
 var _ gotv.Store = (*Disk)(nil)
 var _ gotv.Broadcaster = (*Disk)(nil)
+var _ gotv.StreamStore = (*Disk)(nil)
+var _ gotv.StreamBroadcaster = (*Disk)(nil)
+var _ gotv.FragmentMetadataBroadcaster = (*Disk)(nil)
+var _ gotv.SizeBroadcaster = (*Disk)(nil)
+var _ gotv.Deleter = (*Disk)(nil)
+var _ gotv.DelayBroadcaster = (*Disk)(nil)
+var _ gotv.MatchInfoBroadcaster = (*Disk)(nil)
 
 // Disk fragment disk file based GOTV+ Broadcasting Engine
 type Disk struct {
-	password string // password is Engine-global
-	dir      string // Work dir
+	sync.RWMutex
+	password string                   // password is Engine-global
+	dir      string                   // Work dir
+	delay    time.Duration            // broadcast delay of every match
+	delays   map[string]time.Duration // key=token value=broadcast delay overriding delay
+	match    map[string]*match        // key=token value=cached index
 }
 
+func (d *Disk) matchDir(token string) string {
+	return filepath.Join(d.dir, token)
+}
 func (d *Disk) deltaFramePath(token string, fragment int) string {
-	return path.Join(d.dir, fmt.Sprintf("%s_%d_delta.bin", token, fragment))
+	return filepath.Join(d.matchDir(token), fmt.Sprintf("%d_delta.bin", fragment))
 }
 func (d *Disk) startFramePath(token string, fragment int) string {
-	return path.Join(d.dir, fmt.Sprintf("%s_%d_start.bin", token, fragment))
+	return filepath.Join(d.matchDir(token), fmt.Sprintf("%d_start.bin", fragment))
 }
 func (d *Disk) fullFramePath(token string, fragment int) string {
-	return path.Join(d.dir, fmt.Sprintf("%s_%d_full.bin", token, fragment))
+	return filepath.Join(d.matchDir(token), fmt.Sprintf("%d_full.bin", fragment))
 }
-func (d *Disk) syncPath(token string) string {
-	return path.Join(d.dir, fmt.Sprintf("%s_sync.json", token))
+
+// validToken reports whether token can be used as directory name
+func validToken(token string) bool {
+	return token != "" && token != "." && token != ".." && !strings.ContainsAny(token, `/\`)
 }
 
-// GetDelta implements gotv.Broadcaster
-func (d *Disk) GetDelta(token string, fragment int) ([]byte, error) {
-	b, err := os.ReadFile(d.deltaFramePath(token, fragment))
+// openFrame opens fragment file for streaming
+func openFrame(p string, notFound error) (io.ReadCloser, int64, error) {
+	f, err := os.Open(p)
 	if err != nil {
 		if xerrors.Is(err, os.ErrNotExist) {
-			return nil, gotv.ErrFragmentNotFound
+			return nil, 0, notFound
 		}
+		return nil, 0, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, st.Size(), nil
+}
+
+// readFrame reads whole fragment file
+func readFrame(p string, notFound error) ([]byte, error) {
+	r, _, err := openFrame(p, notFound)
+	if err != nil {
 		return nil, err
 	}
-	return b, err
+	defer r.Close()
+	return io.ReadAll(r)
 }
 
-// GetFull implements gotv.Broadcaster
-func (d *Disk) GetFull(token string, fragment int) ([]byte, error) {
-	b, err := os.ReadFile(d.fullFramePath(token, fragment))
+// writeFrame writes fragment file from r atomically, then appends e to index and applies it to cache.
+// Match must exist unless e is start entry.
+func (d *Disk) writeFrame(token string, p string, e entry, r io.Reader) error {
+	if e.Kind != entryStart && !d.isMatchExist(token) {
+		return gotv.ErrMatchNotFound
+	}
+	n, err := writeFileAtomic(p, r)
 	if err != nil {
-		if xerrors.Is(err, os.ErrNotExist) {
-			return nil, gotv.ErrFragmentNotFound
+		return err
+	}
+	e.Size = n
+
+	d.Lock()
+	defer d.Unlock()
+	m, ok := d.match[token]
+	if !ok {
+		if e.Kind != entryStart {
+			// match is deleted while writing
+			os.Remove(p)
+			return gotv.ErrMatchNotFound
 		}
-		return nil, err
+		m = newMatch(d.matchDelay(token))
+		d.match[token] = m
+	}
+	if err := appendIndex(d.matchDir(token), e); err != nil {
+		return err
+	}
+	m.apply(e)
+	return nil
+}
+
+func (d *Disk) isMatchExist(token string) bool {
+	d.RLock()
+	defer d.RUnlock()
+	_, ok := d.match[token]
+	return ok
+}
+
+// hasFrame reports whether index has fragment of kind
+func (d *Disk) hasFrame(token string, fragment int, kind entryKind) bool {
+	d.RLock()
+	defer d.RUnlock()
+	m, ok := d.match[token]
+	if !ok {
+		return false
+	}
+	f, ok := m.Fragments[fragment]
+	if !ok {
+		return false
 	}
-	return b, err
+	if kind == entryFull {
+		return f.HasFull
+	}
+	return f.HasDelta
+}
+
+// GetMatchSize implements gotv.SizeBroadcaster
+func (d *Disk) GetMatchSize(ctx context.Context, token string) (gotv.MatchSize, error) {
+	d.RLock()
+	defer d.RUnlock()
+	m, ok := d.match[token]
+	if !ok {
+		return gotv.MatchSize{}, gotv.ErrMatchNotFound
+	}
+	return gotv.MatchSize{
+		Bytes:     m.size(),
+		Fragments: len(m.Fragments),
+	}, nil
+}
+
+// GetDeltaStream implements gotv.StreamBroadcaster
+func (d *Disk) GetDeltaStream(ctx context.Context, token string, fragment int) (io.ReadCloser, int64, error) {
+	if !d.hasFrame(token, fragment, entryDelta) {
+		return nil, 0, gotv.ErrFragmentNotFound
+	}
+	return openFrame(d.deltaFramePath(token, fragment), gotv.ErrFragmentNotFound)
+}
+
+// GetFullStream implements gotv.StreamBroadcaster
+func (d *Disk) GetFullStream(ctx context.Context, token string, fragment int) (io.ReadCloser, int64, error) {
+	if !d.hasFrame(token, fragment, entryFull) {
+		return nil, 0, gotv.ErrFragmentNotFound
+	}
+	return openFrame(d.fullFramePath(token, fragment), gotv.ErrFragmentNotFound)
+}
+
+// GetStartStream implements gotv.StreamBroadcaster
+func (d *Disk) GetStartStream(ctx context.Context, token string, fragment int) (io.ReadCloser, int64, error) {
+	if err := d.checkSignupFragment(token, fragment); err != nil {
+		return nil, 0, err
+	}
+	return openFrame(d.startFramePath(token, fragment), gotv.ErrFragmentNotFound)
+}
+
+// GetFragmentMetadata implements gotv.FragmentMetadataBroadcaster
+func (d *Disk) GetFragmentMetadata(ctx context.Context, token string, fragment int) (gotv.FragmentMetadata, error) {
+	d.RLock()
+	defer d.RUnlock()
+	m, ok := d.match[token]
+	if !ok {
+		return gotv.FragmentMetadata{}, gotv.ErrMatchNotFound
+	}
+	f, ok := m.Fragments[fragment]
+	if !ok {
+		return gotv.FragmentMetadata{}, gotv.ErrFragmentNotFound
+	}
+	return gotv.FragmentMetadata{
+		Tick:      f.Tick,
+		EndTick:   f.EndTick,
+		Final:     f.Final,
+		Timestamp: f.At.UnixMilli(),
+		Full:      int(f.Full),
+		Delta:     int(f.Delta),
+	}, nil
+}
+
+// GetDelta implements gotv.Broadcaster
+func (d *Disk) GetDelta(token string, fragment int) ([]byte, error) {
+	if !d.hasFrame(token, fragment, entryDelta) {
+		return nil, gotv.ErrFragmentNotFound
+	}
+	return readFrame(d.deltaFramePath(token, fragment), gotv.ErrFragmentNotFound)
+}
+
+// GetFull implements gotv.Broadcaster
+func (d *Disk) GetFull(token string, fragment int) ([]byte, error) {
+	if !d.hasFrame(token, fragment, entryFull) {
+		return nil, gotv.ErrFragmentNotFound
+	}
+	return readFrame(d.fullFramePath(token, fragment), gotv.ErrFragmentNotFound)
+}
+
+// checkSignupFragment returns gotv.ErrStartExpired unless fragment is the current signup fragment
+func (d *Disk) checkSignupFragment(token string, fragment int) error {
+	d.RLock()
+	defer d.RUnlock()
+	m, ok := d.match[token]
+	if !ok {
+		return gotv.ErrMatchNotFound
+	}
+	if m.SignupFragment != fragment {
+		return gotv.ErrStartExpired
+	}
+	return nil
 }
 
 // GetStart implements gotv.Broadcaster
 func (d *Disk) GetStart(token string, fragment int) ([]byte, error) {
-	b, err := os.ReadFile(d.startFramePath(token, fragment))
-	if err != nil {
-		if err == os.ErrNotExist {
-			return nil, gotv.ErrMatchNotFound
-		}
+	if err := d.checkSignupFragment(token, fragment); err != nil {
 		return nil, err
 	}
-	return b, err
+	return readFrame(d.startFramePath(token, fragment), gotv.ErrFragmentNotFound)
 }
 
-// GetSyncLatest implements gotv.Broadcaster
-func (d *Disk) GetSyncLatest(token string) (gotv.Sync, error) {
-	ret := gotv.Sync{}
-	b, err := os.ReadFile(d.syncPath(token))
-	if err != nil {
-		if xerrors.Is(err, os.ErrNotExist) {
-			return ret, gotv.ErrMatchNotFound
-		}
-		return ret, err
+// matchDelay returns broadcast delay of token for new match
+func (d *Disk) matchDelay(token string) time.Duration {
+	if delay, ok := d.delays[token]; ok {
+		return delay
 	}
+	return d.delay
+}
 
-	if err := json.Unmarshal(b, &ret); err != nil {
-		return ret, err
+// GetMatchInfo implements gotv.MatchInfoBroadcaster
+func (d *Disk) GetMatchInfo(ctx context.Context, token string) (gotv.MatchInfo, error) {
+	d.RLock()
+	defer d.RUnlock()
+	m, ok := d.match[token]
+	if !ok {
+		return gotv.MatchInfo{}, gotv.ErrMatchNotFound
 	}
-	return ret, nil
+	return m.MatchInfo(), nil
 }
 
-// GetSync implements gotv.Broadcaster
-func (d *Disk) GetSync(token string, fragment int) (gotv.Sync, error) {
-	ret := gotv.Sync{}
-	b, err := os.ReadFile(d.syncPath(token))
-	if err != nil {
-		if xerrors.Is(err, os.ErrNotExist) {
-			return ret, gotv.ErrMatchNotFound
-		}
-		return ret, err
+// GetMatchDelay implements gotv.DelayBroadcaster
+func (d *Disk) GetMatchDelay(ctx context.Context, token string) (time.Duration, error) {
+	d.RLock()
+	defer d.RUnlock()
+	if m, ok := d.match[token]; ok {
+		return m.Delay, nil
+	}
+	return d.matchDelay(token), nil
+}
+
+// SetMatchDelay implements gotv.DelayBroadcaster. It also applies to the match started later.
+// Override of stored match is kept in its index, so it survives restart.
+func (d *Disk) SetMatchDelay(ctx context.Context, token string, delay time.Duration) error {
+	d.Lock()
+	defer d.Unlock()
+	d.delays[token] = delay
+	m, ok := d.match[token]
+	if !ok {
+		return nil
+	}
+	e := entry{
+		Kind:  entryDelay,
+		At:    time.Now(),
+		Delay: delay,
+	}
+	if err := appendIndex(d.matchDir(token), e); err != nil {
+		return err
 	}
+	m.apply(e)
+	return nil
+}
 
-	if err := json.Unmarshal(b, &ret); err != nil {
-		return ret, err
+// GetSyncLatest implements gotv.Broadcaster
+func (d *Disk) GetSyncLatest(token string) (gotv.Sync, error) {
+	return d.GetSync(token, 0)
+}
+
+// GetSync implements gotv.Broadcaster
+func (d *Disk) GetSync(token string, fragment int) (gotv.Sync, error) {
+	d.RLock()
+	defer d.RUnlock()
+	m, ok := d.match[token]
+	if !ok {
+		return gotv.Sync{}, gotv.ErrMatchNotFound
 	}
-	return ret, nil
+	return gotv.SelectSync(m, fragment, time.Now())
 }
 
 // OnDelta implements gotv.Store
 func (d *Disk) OnDelta(token string, fragment int, endtick int, at time.Time, final bool, b []byte) error {
-	return os.WriteFile(d.deltaFramePath(token, fragment), b, 0755)
+	return d.OnDeltaStream(context.Background(), token, fragment, endtick, at, final, bytes.NewReader(b))
+}
+
+// OnDeltaStream implements gotv.StreamStore
+func (d *Disk) OnDeltaStream(ctx context.Context, token string, fragment int, endtick int, at time.Time, final bool, r io.Reader) error {
+	return d.writeFrame(token, d.deltaFramePath(token, fragment), entry{
+		Kind:     entryDelta,
+		Fragment: fragment,
+		At:       at,
+		EndTick:  endtick,
+		Final:    final,
+	}, r)
 }
 
 // OnFull implements gotv.Store
 func (d *Disk) OnFull(token string, fragment int, tick int, at time.Time, b []byte) error {
-	s := gotv.Sync{}
-	b, err := os.ReadFile(d.syncPath(token))
-	if err != nil {
-		if xerrors.Is(err, os.ErrNotExist) {
-			return gotv.ErrMatchNotFound
-		}
-		return err
+	return d.OnFullStream(context.Background(), token, fragment, tick, at, bytes.NewReader(b))
+}
+
+// OnFullStream implements gotv.StreamStore
+func (d *Disk) OnFullStream(ctx context.Context, token string, fragment int, tick int, at time.Time, r io.Reader) error {
+	return d.writeFrame(token, d.fullFramePath(token, fragment), entry{
+		Kind:     entryFull,
+		Fragment: fragment,
+		At:       at,
+		Tick:     tick,
+	}, r)
+}
+
+// OnStart implements gotv.Store
+func (d *Disk) OnStart(token string, fragment int, sf gotv.StartFrame) error {
+	return d.OnStartStream(context.Background(), token, fragment, sf, bytes.NewReader(sf.Body))
+}
+
+// OnStartStream implements gotv.StreamStore
+func (d *Disk) OnStartStream(ctx context.Context, token string, fragment int, sf gotv.StartFrame, r io.Reader) error {
+	if !validToken(token) {
+		return xerrors.Errorf("invalid token %q", token)
 	}
-	if err := json.Unmarshal(b, &s); err != nil {
+	if err := os.MkdirAll(d.matchDir(token), 0755); err != nil {
 		return err
 	}
-	s.Fragment = fragment
-	s.Tick = tick
-	b, err = json.Marshal(s)
-	if err != nil {
+	return d.writeFrame(token, d.startFramePath(token, fragment), entry{
+		Kind:             entryStart,
+		Fragment:         fragment,
+		At:               sf.At,
+		Tps:              sf.Tps,
+		KeyframeInterval: sf.KeyframeInterval,
+		Map:              sf.Map,
+		Protocol:         sf.Protocol,
+	}, r)
+}
+
+// Auth implements gotv.Store
+func (d *Disk) Auth(token string, auth string) error {
+	if auth != d.password {
+		return gotv.ErrInvalidAuth
+	}
+	return nil
+}
+
+// DeleteMatch implements gotv.Deleter
+func (d *Disk) DeleteMatch(ctx context.Context, token string) error {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.match[token]; !ok {
+		return gotv.ErrMatchNotFound
+	}
+	if err := os.RemoveAll(d.matchDir(token)); err != nil {
 		return err
 	}
-	if err := os.WriteFile(d.syncPath(token), b, 0755); err != nil {
+	delete(d.match, token)
+	return nil
+}
+
+// DeleteFragment implements gotv.Deleter
+func (d *Disk) DeleteFragment(ctx context.Context, token string, fragment int) error {
+	d.Lock()
+	defer d.Unlock()
+	m, ok := d.match[token]
+	if !ok {
+		return gotv.ErrMatchNotFound
+	}
+	if _, ok := m.Fragments[fragment]; !ok {
+		return gotv.ErrFragmentNotFound
+	}
+	for _, p := range []string{d.fullFramePath(token, fragment), d.deltaFramePath(token, fragment)} {
+		if err := os.Remove(p); err != nil && !xerrors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	e := entry{
+		Kind:     entryDelete,
+		Fragment: fragment,
+		At:       time.Now(),
+	}
+	if err := appendIndex(d.matchDir(token), e); err != nil {
 		return err
 	}
-	return os.WriteFile(d.fullFramePath(token, fragment), b, 0755)
+	m.apply(e)
+	return nil
 }
 
-// OnStart implements gotv.Store
-func (d *Disk) OnStart(token string, fragment int, sf gotv.StartFrame) error {
-	s := gotv.Sync{
-		Fragment:       fragment,
-		SignupFragment: fragment,
-		TickPerSecond:  int(sf.Tps),
-		Map:            sf.Map,
-		Protocol:       sf.Protocol,
-	}
-	b, err := json.Marshal(s)
+// recover rebuilds cached index of every match under dir
+func (d *Disk) recover() error {
+	dirs, err := os.ReadDir(d.dir)
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(d.syncPath(token), b, 0755); err != nil {
-		return err
+	for _, dir := range dirs {
+		if !dir.IsDir() || !validToken(dir.Name()) {
+			continue
+		}
+		token := dir.Name()
+		entries, err := loadIndex(d.matchDir(token))
+		if err != nil {
+			if xerrors.Is(err, os.ErrNotExist) {
+				// not a match directory
+				continue
+			}
+			return xerrors.Errorf("failed to load index of %s: %w", token, err)
+		}
+		if err := removeTempFiles(d.matchDir(token)); err != nil {
+			return err
+		}
+		m := newMatch(d.delay)
+		for _, e := range entries {
+			m.apply(e)
+		}
+		if len(m.Start) == 0 {
+			// start fragment never made it to the index
+			continue
+		}
+		d.match[token] = m
 	}
-	return os.WriteFile(d.startFramePath(token, fragment), sf.Body, 0755)
+	return nil
 }
 
-// Auth implements gotv.Store
-func (d *Disk) Auth(token string, auth string) error {
-	if auth != d.password {
-		return gotv.ErrInvalidAuth
-	}
+// Close waits for writes in progress. Disk keeps no file open between requests, so nothing else is released.
+func (d *Disk) Close() error {
+	d.Lock()
+	defer d.Unlock()
 	return nil
 }
 
-// NewDiskGOTV Get new pointer of Disk GOTV+ Engine
-func NewDiskGOTV(password string, dir string) *Disk {
-	p := path.Join(".", dir)
-	os.MkdirAll(p, 0755)
-	return &Disk{
+// NewDiskGOTV Get new pointer of Disk GOTV+ Engine.
+// Matches stored in dir by previous process are recovered, so game servers and clients can resume.
+func NewDiskGOTV(password string, dir string, opts ...Option) (*Disk, error) {
+	p := filepath.Clean(dir)
+	if err := os.MkdirAll(p, 0755); err != nil {
+		return nil, err
+	}
+	d := &Disk{
+		RWMutex:  sync.RWMutex{},
 		password: password,
 		dir:      p,
+		delays:   map[string]time.Duration{},
+		match:    map[string]*match{},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if err := d.recover(); err != nil {
+		return nil, err
 	}
+	return d, nil
 }
@@ -0,0 +1,142 @@
+package disk_test
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/disk"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+func TestDisk(t *testing.T) {
+	asserts := assert.New(t)
+	dir := t.TempDir()
+	d, err := disk.NewDiskGOTV("gopher", dir)
+	asserts.NoError(err)
+
+	now := time.Now()
+	asserts.ErrorIs(d.OnFull("match", 1, 128, now, []byte("full")), gotv.ErrMatchNotFound)
+	asserts.Error(d.OnStart("..", 1, gotv.StartFrame{At: now, Tps: 128, Body: []byte("start")}))
+	asserts.NoError(d.OnStart("match", 1, gotv.StartFrame{At: now, Tps: 128, Map: "de_dust2", Protocol: 4, Body: []byte("start")}))
+	for i := 1; i <= 10; i++ {
+		asserts.NoError(d.OnFull("match", i, i*384, now, []byte("full")))
+		asserts.NoError(d.OnDelta("match", i, (i+1)*384, now, i == 10, []byte("delta")))
+	}
+
+	// full fragment keeps its own body
+	b, err := d.GetFull("match", 1)
+	asserts.NoError(err)
+	asserts.Equal([]byte("full"), b)
+	b, err = d.GetStart("match", 1)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start"), b)
+	_, err = d.GetFull("match", 11)
+	asserts.ErrorIs(err, gotv.ErrFragmentNotFound)
+
+	s, err := d.GetSyncLatest("match")
+	asserts.NoError(err)
+	asserts.Equal(3, s.Fragment)
+	asserts.Equal(3*384, s.Tick)
+	asserts.Equal(3.0, s.KeyframeInterval)
+	asserts.Equal("de_dust2", s.Map)
+	s, err = d.GetSync("match", 5)
+	asserts.NoError(err)
+	asserts.Equal(5, s.Fragment)
+
+	m, err := d.GetFragmentMetadata(context.Background(), "match", 10)
+	asserts.NoError(err)
+	asserts.Equal(gotv.FragmentMetadata{Tick: 3840, EndTick: 4224, Final: true, Timestamp: now.UnixMilli(), Full: 4, Delta: 5}, m)
+
+	asserts.NoError(d.DeleteFragment(context.Background(), "match", 10))
+	size, err := d.GetMatchSize(context.Background(), "match")
+	asserts.NoError(err)
+	asserts.Equal(gotv.MatchSize{Bytes: 5 + 9*9, Fragments: 9}, size)
+
+	// every write is in the index, and no temporary file is left
+	entries, err := os.ReadDir(filepath.Join(dir, "match"))
+	asserts.NoError(err)
+	for _, e := range entries {
+		asserts.False(strings.Contains(e.Name(), ".tmp"), e.Name())
+	}
+	f, err := os.Open(filepath.Join(dir, "match", "index.jsonl"))
+	asserts.NoError(err)
+	defer f.Close()
+	lines := 0
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		lines++
+	}
+	asserts.Equal(1+10*2+1, lines)
+
+	asserts.NoError(d.DeleteMatch(context.Background(), "match"))
+	_, err = os.Stat(filepath.Join(dir, "match"))
+	asserts.ErrorIs(err, os.ErrNotExist)
+	_, err = d.GetSyncLatest("match")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+}
+
+func TestRecovery(t *testing.T) {
+	asserts := assert.New(t)
+	dir := t.TempDir()
+	d, err := disk.NewDiskGOTV("gopher", dir)
+	asserts.NoError(err)
+
+	now := time.Now()
+	asserts.NoError(d.OnStart("match", 1, gotv.StartFrame{At: now, Tps: 128, Map: "de_dust2", Protocol: 4, Body: []byte("start")}))
+	for i := 1; i <= 10; i++ {
+		asserts.NoError(d.OnFull("match", i, i*384, now, []byte("full")))
+		asserts.NoError(d.OnDelta("match", i, (i+1)*384, now, false, []byte("delta")))
+	}
+	asserts.NoError(d.OnStart("match", 11, gotv.StartFrame{At: now, Tps: 128, Map: "de_inferno", Protocol: 4, Body: []byte("start2")}))
+	for i := 11; i <= 15; i++ {
+		asserts.NoError(d.OnFull("match", i, i*384, now, []byte("full")))
+		asserts.NoError(d.OnDelta("match", i, (i+1)*384, now, false, []byte("delta")))
+	}
+	expected, err := d.GetSync("match", 12)
+	asserts.NoError(err)
+	expectedSize, err := d.GetMatchSize(context.Background(), "match")
+	asserts.NoError(err)
+
+	// crash while writing fragment 16 and its index entry
+	asserts.NoError(os.WriteFile(filepath.Join(dir, "match", ".16_full.bin.tmp123"), []byte("fu"), 0644))
+	f, err := os.OpenFile(filepath.Join(dir, "match", "index.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+	asserts.NoError(err)
+	_, err = f.WriteString(`{"kind":"full","fragm`)
+	asserts.NoError(err)
+	asserts.NoError(f.Close())
+
+	d, err = disk.NewDiskGOTV("gopher", dir)
+	asserts.NoError(err)
+	s, err := d.GetSync("match", 12)
+	asserts.NoError(err)
+	asserts.Equal(11, s.SignupFragment)
+	asserts.Equal(expected.Fragment, s.Fragment)
+	asserts.Equal(expected.Tick, s.Tick)
+	asserts.Equal("de_inferno", s.Map)
+	asserts.Equal(128, s.TickPerSecond)
+	asserts.Equal(4, s.Protocol)
+	size, err := d.GetMatchSize(context.Background(), "match")
+	asserts.NoError(err)
+	asserts.Equal(expectedSize, size)
+	b, err := d.GetStart("match", 11)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start2"), b)
+	_, err = os.Stat(filepath.Join(dir, "match", ".16_full.bin.tmp123"))
+	asserts.ErrorIs(err, os.ErrNotExist)
+
+	// game server resumes after restart
+	asserts.NoError(d.OnFull("match", 16, 16*384, now, []byte("full")))
+	asserts.NoError(d.OnDelta("match", 16, 17*384, now, false, []byte("delta")))
+	d, err = disk.NewDiskGOTV("gopher", dir)
+	asserts.NoError(err)
+	m, err := d.GetFragmentMetadata(context.Background(), "match", 16)
+	asserts.NoError(err)
+	asserts.Equal(16*384, m.Tick)
+	asserts.Equal(17*384, m.EndTick)
+}
@@ -0,0 +1,279 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+// indexFile append-only index in each match directory. One JSON entry per line.
+const indexFile = "index.jsonl"
+
+// entryKind kind of index entry
+type entryKind string
+
+const (
+	entryStart  entryKind = "start"
+	entryFull   entryKind = "full"
+	entryDelta  entryKind = "delta"
+	entryDelete entryKind = "delete" // fragment is deleted
+	entryDelay  entryKind = "delay"  // broadcast delay is overridden
+)
+
+// entry single line of index.
+// Entry is appended after its fragment file is renamed into place, so fragment files without entry are ignored.
+type entry struct {
+	Kind             entryKind     `json:"kind"`
+	Fragment         int           `json:"fragment"`
+	At               time.Time     `json:"at"` // time relay received the fragment
+	Size             int64         `json:"size,omitempty"`
+	Tick             int           `json:"tick,omitempty"`
+	EndTick          int           `json:"endtick,omitempty"`
+	Final            bool          `json:"final,omitempty"`
+	Tps              float64       `json:"tps,omitempty"`
+	KeyframeInterval float64       `json:"keyframe_interval,omitempty"`
+	Map              string        `json:"map,omitempty"`
+	Protocol         int           `json:"protocol,omitempty"`
+	Delay            time.Duration `json:"delay,omitempty"`
+}
+
+// appendIndex appends e to index file in dir
+func appendIndex(dir string, e entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, indexFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadIndex reads every entry of index file in dir.
+// Entry torn by crash is the last line without newline. It is truncated so next append starts a new line.
+// Other malformed lines are skipped.
+func loadIndex(dir string) ([]entry, error) {
+	p := filepath.Join(dir, indexFile)
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var entries []entry
+	offset := 0
+	for {
+		i := bytes.IndexByte(b[offset:], '\n')
+		if i < 0 {
+			break
+		}
+		var e entry
+		if err := json.Unmarshal(b[offset:offset+i], &e); err == nil {
+			entries = append(entries, e)
+		}
+		offset += i + 1
+	}
+	if offset < len(b) {
+		if err := os.Truncate(p, int64(offset)); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// removeTempFiles removes temporary files left by writeFileAtomic interrupted by crash
+func removeTempFiles(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") && strings.Contains(e.Name(), ".tmp") {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes r to p through temporary file and rename, so neither readers nor crashes see torn file.
+func writeFileAtomic(p string, r io.Reader) (int64, error) {
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".tmp*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// match in-memory cache of index
+type match struct {
+	ReceivedAt       time.Time
+	Latest           int
+	SignupFragment   int
+	TickPerSecond    float64
+	KeyframeInterval float64 // from start request, or estimated from full fragments
+	keyframeSent     bool    // game server sent keyframe_interval in start request
+	Protocol         int
+	Map              string
+	Delay            time.Duration
+	Start            map[int]int64     // key=fragment_number value=size
+	Fragments        map[int]*fragment // key=fragment_number
+}
+
+// fragment cached index of full and delta fragment
+type fragment struct {
+	At       time.Time
+	Tick     int
+	EndTick  int
+	Final    bool
+	Full     int64 // size of full fragment
+	Delta    int64 // size of delta fragment
+	HasFull  bool
+	HasDelta bool
+}
+
+func newMatch(delay time.Duration) *match {
+	return &match{
+		Delay:     delay,
+		Start:     map[int]int64{},
+		Fragments: map[int]*fragment{},
+	}
+}
+
+// apply updates cache with index entry
+func (m *match) apply(e entry) {
+	if e.Kind != entryDelete && e.Kind != entryDelay && e.At.After(m.ReceivedAt) {
+		m.ReceivedAt = e.At
+	}
+	switch e.Kind {
+	case entryStart:
+		m.Start[e.Fragment] = e.Size
+		m.SignupFragment = e.Fragment
+		m.TickPerSecond = e.Tps
+		m.KeyframeInterval = e.KeyframeInterval
+		m.keyframeSent = e.KeyframeInterval > 0
+		m.Protocol = e.Protocol
+		m.Map = e.Map
+	case entryFull:
+		f := m.fragmentOf(e.Fragment)
+		f.At = e.At
+		f.Tick = e.Tick
+		f.Full = e.Size
+		f.HasFull = true
+		m.Latest = e.Fragment
+		m.estimateKeyframeInterval(e.Fragment)
+	case entryDelta:
+		f := m.fragmentOf(e.Fragment)
+		if f.At.IsZero() {
+			f.At = e.At
+		}
+		f.EndTick = e.EndTick
+		f.Final = e.Final
+		f.Delta = e.Size
+		f.HasDelta = true
+	case entryDelete:
+		delete(m.Fragments, e.Fragment)
+	case entryDelay:
+		m.Delay = e.Delay
+	}
+}
+
+// fragmentOf returns cached fragment n, creating it if not exist
+func (m *match) fragmentOf(n int) *fragment {
+	f, ok := m.Fragments[n]
+	if !ok {
+		f = &fragment{}
+		m.Fragments[n] = f
+	}
+	return f
+}
+
+// estimateKeyframeInterval estimates keyframe interval from fragment and the previous one
+// unless game server sent it in start request
+func (m *match) estimateKeyframeInterval(fragment int) {
+	if m.keyframeSent {
+		return
+	}
+	prev, ok := m.Fragments[fragment-1]
+	if !ok || !prev.HasFull {
+		return
+	}
+	cur := m.Fragments[fragment]
+	if k := gotv.EstimateKeyframeInterval(
+		gotv.FragmentInfo{At: prev.At, Tick: prev.Tick},
+		gotv.FragmentInfo{At: cur.At, Tick: cur.Tick},
+		m.TickPerSecond,
+	); k > 0 {
+		m.KeyframeInterval = k
+	}
+}
+
+// size total bytes of start, full and delta fragments
+func (m *match) size() int64 {
+	var n int64
+	for _, s := range m.Start {
+		n += s
+	}
+	for _, f := range m.Fragments {
+		n += f.Full + f.Delta
+	}
+	return n
+}
+
+// MatchInfo implements gotv.SyncSource
+func (m *match) MatchInfo() gotv.MatchInfo {
+	return gotv.MatchInfo{
+		SignupFragment:   m.SignupFragment,
+		Latest:           m.Latest,
+		ReceivedAt:       m.ReceivedAt,
+		TickPerSecond:    m.TickPerSecond,
+		KeyframeInterval: m.KeyframeInterval,
+		Map:              m.Map,
+		Protocol:         m.Protocol,
+		Delay:            m.Delay,
+	}
+}
+
+// FragmentInfo implements gotv.SyncSource
+func (m *match) FragmentInfo(fragment int) (gotv.FragmentInfo, bool) {
+	f, ok := m.Fragments[fragment]
+	if !ok {
+		return gotv.FragmentInfo{}, false
+	}
+	return gotv.FragmentInfo{
+		At:       f.At,
+		Tick:     f.Tick,
+		EndTick:  f.EndTick,
+		Final:    f.Final,
+		HasFull:  f.HasFull,
+		HasDelta: f.HasDelta,
+	}, true
+}
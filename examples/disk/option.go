@@ -0,0 +1,16 @@
+package disk
+
+import (
+	"time"
+)
+
+// Option Disk engine option
+type Option func(d *Disk)
+
+// WithDelay delays /sync by d like tv_delay. Fragments received within d are never served as /sync fragment.
+// Use SetMatchDelay to override it per match.
+func WithDelay(d time.Duration) Option {
+	return func(disk *Disk) {
+		disk.delay = d
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/disk"
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/fanout"
+	"github.com/FlowingSPDG/gotv-plus-go/forward"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+var (
+	auth    string
+	port    int
+	archive string
+	queue   int
+
+	forwardURL  string
+	forwardAuth string
+)
+
+func main() {
+	flag.StringVar(&auth, "auth", "SuperSecureStringDoNotShare", "tv_broadcast_origin_auth \"SuperSecureStringDoNotShare\"")
+	flag.IntVar(&port, "port", 8080, "Port to listen")
+	flag.StringVar(&archive, "archive", "./archive", "Directory to archive matches")
+	flag.IntVar(&queue, "queue", 256, "Fragments to queue while archive is slow. Fragments are dropped from archive when the queue is full")
+	flag.StringVar(&forwardURL, "forward", "", "tv_broadcast_url of another GOTV+ server to forward matches, e.g. \"http://partner:8080/gotv\". Disabled if empty")
+	flag.StringVar(&forwardAuth, "forward-auth", "", "tv_broadcast_origin_auth of the server to forward matches")
+	flag.Parse()
+
+	// Live viewers are served from memory, and every fragment is archived to disk in background
+	live := inmemory.NewInmemoryGOTV(auth)
+	defer live.Close()
+	d, err := disk.NewDiskGOTV(auth, archive)
+	if err != nil {
+		panic(err)
+	}
+	defer d.Close()
+	children := []fanout.Child{
+		{Name: "live", Store: gotv.WrapStore(live), Policy: fanout.Policy{Required: true}},
+		{Name: "archive", Store: gotv.WrapStore(d), Policy: fanout.Policy{Async: true, Queue: queue, Retries: 3}},
+	}
+	if forwardURL != "" {
+		// Remote server is best-effort, so it never delays live viewers
+		children = append(children, fanout.Child{Name: "forward", Store: forward.New(forwardURL, forwardAuth), Policy: fanout.Policy{Async: true, Queue: queue, Retries: 3, Backoff: time.Second}})
+	}
+	s := fanout.New(children, fanout.WithErrorHandler(func(name string, err error) {
+		log.Println("Failed to write to", name, ":", err)
+	}))
+	defer s.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/gotv/", http.StripPrefix("/gotv", gotv.NewHTTPHandler(s, gotv.WrapBroadcaster(live)))) // /gotv
+
+	p := fmt.Sprintf("%s:%d", "", port)
+
+	// Start server
+	log.Println("Start listening on:", p)
+	if err := http.ListenAndServe(p, mux); err != nil {
+		panic(err)
+	}
+}
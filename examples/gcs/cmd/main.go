@@ -5,32 +5,43 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 
+	"github.com/FlowingSPDG/gotv-plus-go/blobstore"
 	"github.com/FlowingSPDG/gotv-plus-go/examples/gcs"
 	"github.com/FlowingSPDG/gotv-plus-go/gotv"
 )
 
 var (
-	auth string
-	port int
+	auth     string
+	port     int
+	delay    time.Duration
+	bucket   string
+	prefix   string
+	redirect string
 )
 
 func main() {
 	flag.StringVar(&auth, "auth", "SuperSecureStringDoNotShare", "tv_broadcast_origin_auth \"SuperSecureStringDoNotShare\"")
 	flag.IntVar(&port, "port", 8080, "Port to listen")
+	flag.DurationVar(&delay, "delay", 0, "Broadcast delay like tv_delay, e.g. 90s")
+	flag.StringVar(&bucket, "bucket", "", "Bucket to store fragments")
+	flag.StringVar(&prefix, "prefix", "", "Object name prefix, e.g. \"gotv/\"")
+	flag.StringVar(&redirect, "redirect", "", "Redirect clients to this base URL of objects, e.g. \"https://storage.googleapis.com/<bucket>\". Fragments are served through relay if empty")
 	flag.Parse()
 
+	// Set STORAGE_EMULATOR_HOST to use local fake GCS server
 	ctx := context.Background()
 	s, err := storage.NewClient(ctx)
 	if err != nil {
 		panic(err)
 	}
 
-	m := gcs.NewCloudStorageGOTV(s, auth, 8)
+	m := gcs.NewCloudStorageGOTV(s, bucket, auth, delay, blobstore.WithPrefix(prefix), blobstore.WithRedirect(redirect))
 	app := fiber.New()
 	g := app.Group("/gotv") // /gotv
 	g.Use(logger.New())
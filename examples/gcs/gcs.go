@@ -1,79 +1,104 @@
 package gcs
 
 import (
+	"context"
+	"io"
 	"time"
 
 	"cloud.google.com/go/storage"
-	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+	"golang.org/x/xerrors"
+	"google.golang.org/api/iterator"
+
+	"github.com/FlowingSPDG/gotv-plus-go/blobstore"
 )
 
 //
 // Google Cloud Storage GOTV+ Engine example
 //
+// Bucket adapts GCS bucket to blobstore.BlobStore, and blobstore.Engine does the rest.
+//
 
-var _ gotv.Store = (*CloudStorage)(nil)
-var _ gotv.Broadcaster = (*CloudStorage)(nil)
+var _ blobstore.BlobStore = (*Bucket)(nil)
 
-// CloudStorage GCS based GOTV+ Broadcasting Engine
-type CloudStorage struct {
-	s        *storage.Client // Firebase Storage and Google Cloud Storage is identical
-	password string          // password
-	delay    int             // frag delay
+// Bucket GCS bucket as blobstore.BlobStore
+type Bucket struct {
+	s      *storage.Client // Firebase Storage and Google Cloud Storage is identical
+	bucket string          // bucket name
 }
 
-// Auth implements gotv.Store
-func (c *CloudStorage) Auth(token string, auth string) error {
-	if auth != c.password {
-		return gotv.ErrInvalidAuth
+// Put implements blobstore.BlobStore
+func (b *Bucket) Put(ctx context.Context, key string, r io.Reader, attrs blobstore.Attrs) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := b.s.Bucket(b.bucket).Object(key).NewWriter(ctx)
+	w.ChunkSize = 0 // fragments are small enough to upload in single request
+	w.ContentType = attrs.ContentType
+	w.CacheControl = attrs.CacheControl
+	w.StorageClass = attrs.StorageClass
+	w.Metadata = attrs.Metadata
+	n, err := io.Copy(w, r)
+	if err != nil {
+		// cancelling ctx aborts the upload
+		cancel()
+		w.Close()
+		return 0, err
 	}
-	return nil
-}
-
-// OnDelta implements gotv.Store
-func (c *CloudStorage) OnDelta(token string, fragment int, endtick int, at time.Time, final bool, b []byte) error {
-	panic("unimplemented")
-}
-
-// OnFull implements gotv.Store
-func (c *CloudStorage) OnFull(token string, fragment int, tick int, at time.Time, b []byte) error {
-	panic("unimplemented")
-}
-
-// OnStart implements gotv.Store
-func (c *CloudStorage) OnStart(token string, fragment int, f gotv.StartFrame) error {
-	panic("unimplemented")
-}
-
-// GetDelta implements gotv.Broadcaster
-func (c *CloudStorage) GetDelta(token string, fragment int) ([]byte, error) {
-	panic("unimplemented")
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
-// GetFull implements gotv.Broadcaster
-func (c *CloudStorage) GetFull(token string, fragment int) ([]byte, error) {
-	panic("unimplemented")
+// Get implements blobstore.BlobStore
+func (b *Bucket) Get(ctx context.Context, key string) (io.ReadCloser, blobstore.Info, error) {
+	r, err := b.s.Bucket(b.bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		if xerrors.Is(err, storage.ErrObjectNotExist) {
+			return nil, blobstore.Info{}, blobstore.ErrNotExist
+		}
+		return nil, blobstore.Info{}, err
+	}
+	return r, blobstore.Info{Key: key, Size: r.Attrs.Size, Modified: r.Attrs.LastModified}, nil
 }
 
-// GetStart implements gotv.Broadcaster
-func (c *CloudStorage) GetStart(token string, fragment int) ([]byte, error) {
-	panic("unimplemented")
+// Delete implements blobstore.BlobStore
+func (b *Bucket) Delete(ctx context.Context, key string) error {
+	if err := b.s.Bucket(b.bucket).Object(key).Delete(ctx); err != nil && !xerrors.Is(err, storage.ErrObjectNotExist) {
+		return err
+	}
+	return nil
 }
 
-// GetSync implements gotv.Broadcaster
-func (c *CloudStorage) GetSync(token string, fragment int) (gotv.Sync, error) {
-	panic("unimplemented")
+// List implements blobstore.BlobStore. Listing always contains metadata on GCS.
+func (b *Bucket) List(ctx context.Context, prefix string, metadata bool) ([]blobstore.Info, error) {
+	infos := []blobstore.Info{}
+	it := b.s.Bucket(b.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return infos, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, blobstore.Info{
+			Key:      attrs.Name,
+			Size:     attrs.Size,
+			Modified: attrs.Updated,
+			Metadata: attrs.Metadata,
+		})
+	}
 }
 
-// GetSyncLatest implements gotv.Broadcaster
-func (c *CloudStorage) GetSyncLatest(token string) (gotv.Sync, error) {
-	panic("unimplemented")
+// NewBucket Get new pointer of GCS bucket adapter
+func NewBucket(s *storage.Client, bucket string) *Bucket {
+	return &Bucket{
+		s:      s,
+		bucket: bucket,
+	}
 }
 
 // NewCloudStorageGOTV Get new pointer of GCS GOTV+ Engine
-func NewCloudStorageGOTV(s *storage.Client, password string, delay int) *CloudStorage {
-	return &CloudStorage{
-		s:        s,
-		password: password,
-		delay:    delay,
-	}
+func NewCloudStorageGOTV(s *storage.Client, bucket string, password string, delay time.Duration, opts ...blobstore.Option) *blobstore.Engine {
+	return blobstore.New(NewBucket(s, bucket), password, delay, opts...)
 }
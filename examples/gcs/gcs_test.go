@@ -0,0 +1,163 @@
+package gcs_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+
+	"github.com/FlowingSPDG/gotv-plus-go/blobstore"
+	"github.com/FlowingSPDG/gotv-plus-go/examples/gcs"
+)
+
+// fakeObject object stored in fakeGCS
+type fakeObject struct {
+	Name        string            `json:"name"`
+	Bucket      string            `json:"bucket"`
+	Size        string            `json:"size"`
+	ContentType string            `json:"contentType,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Updated     string            `json:"updated"`
+	data        []byte
+}
+
+// fakeGCS minimal GCS JSON and XML API server which storage.Client uses for single request upload, download, list and delete
+type fakeGCS struct {
+	sync.Mutex
+	objects map[string]*fakeObject // key=bucket/name
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/"):
+		bucket := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/upload/storage/v1/b/"), "/o")
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		o := &fakeObject{}
+		for i := 0; i < 2; i++ {
+			p, err := mr.NextPart()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if i == 0 {
+				err = json.NewDecoder(p).Decode(o)
+			} else {
+				o.data, err = io.ReadAll(p)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		o.Bucket = bucket
+		o.Size = strconv.Itoa(len(o.data))
+		o.Updated = time.Now().Format(time.RFC3339Nano)
+		f.objects[bucket+"/"+o.Name] = o
+		json.NewEncoder(w).Encode(o)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/storage/v1/b/") && strings.HasSuffix(r.URL.Path, "/o"):
+		bucket := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/storage/v1/b/"), "/o")
+		items := []*fakeObject{}
+		for _, o := range f.objects {
+			if o.Bucket == bucket && strings.HasPrefix(o.Name, r.URL.Query().Get("prefix")) {
+				items = append(items, o)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+		json.NewEncoder(w).Encode(map[string]interface{}{"kind": "storage#objects", "items": items})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/storage/v1/b/"):
+		key := strings.Replace(strings.TrimPrefix(r.URL.Path, "/storage/v1/b/"), "/o/", "/", 1)
+		if _, ok := f.objects[key]; !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet:
+		o, ok := f.objects[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", o.ContentType)
+		w.Header().Set("Content-Length", o.Size)
+		w.Write(o.data)
+	default:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+	}
+}
+
+func newFakeGCS(t *testing.T) *storage.Client {
+	srv := httptest.NewServer(&fakeGCS{objects: map[string]*fakeObject{}})
+	t.Cleanup(srv.Close)
+	s, err := storage.NewClient(context.Background(), option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestBucket(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	b := gcs.NewBucket(newFakeGCS(t), "bucket")
+
+	_, _, err := b.Get(ctx, "gotv/match/1_full")
+	asserts.ErrorIs(err, blobstore.ErrNotExist)
+	asserts.NoError(b.Delete(ctx, "gotv/match/1_full"), "missing blob is ignored")
+
+	metadata := map[string]string{"tick": "384", "final": "true"}
+	n, err := b.Put(ctx, "gotv/match/1_full", strings.NewReader("full"), blobstore.Attrs{ContentType: "application/octet-stream", Metadata: metadata})
+	asserts.NoError(err)
+	asserts.Equal(int64(4), n)
+	_, err = b.Put(ctx, "gotv/other/1_full", strings.NewReader("other"), blobstore.Attrs{})
+	asserts.NoError(err)
+
+	r, info, err := b.Get(ctx, "gotv/match/1_full")
+	if asserts.NoError(err) {
+		body, err := io.ReadAll(r)
+		asserts.NoError(err)
+		asserts.NoError(r.Close())
+		asserts.Equal("full", string(body))
+		asserts.Equal("gotv/match/1_full", info.Key)
+		asserts.Equal(int64(4), info.Size)
+	}
+
+	// listing always contains metadata, with lower case keys as stored
+	for _, withMetadata := range []bool{true, false} {
+		infos, err := b.List(ctx, "gotv/match/", withMetadata)
+		asserts.NoError(err)
+		if asserts.Len(infos, 1) {
+			asserts.Equal("gotv/match/1_full", infos[0].Key)
+			asserts.Equal(int64(4), infos[0].Size)
+			asserts.Equal(metadata, infos[0].Metadata)
+		}
+	}
+
+	asserts.NoError(b.Delete(ctx, "gotv/match/1_full"))
+	_, _, err = b.Get(ctx, "gotv/match/1_full")
+	asserts.ErrorIs(err, blobstore.ErrNotExist)
+	infos, err := b.List(ctx, "gotv/", false)
+	asserts.NoError(err)
+	asserts.Len(infos, 1)
+}
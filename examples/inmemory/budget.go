@@ -0,0 +1,154 @@
+package inmemory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+// defaultViewerWindow default duration a match is considered to have active viewers after the last request
+const defaultViewerWindow = 30 * time.Second
+
+// EvictionReason why fragments were evicted
+type EvictionReason string
+
+const (
+	// EvictionRetention fragment was out of retention window
+	EvictionRetention EvictionReason = "retention"
+	// EvictionIdle whole match was idle
+	EvictionIdle EvictionReason = "idle"
+	// EvictionBudget fragment was evicted to keep memory budget
+	EvictionBudget EvictionReason = "budget"
+)
+
+// Eviction reports evicted fragment, or whole match if Reason is EvictionIdle
+type Eviction struct {
+	Token    string
+	Fragment int // evicted fragment. 0 if whole match was evicted
+	Bytes    int64
+	Reason   EvictionReason
+}
+
+// views tracks viewers of a match. guarded by match.RWMutex, not by InMemory.
+type views struct {
+	last        time.Time // last time any client requested the match
+	windowStart time.Time
+	floor       int // oldest fragment requested in current window
+	prevFloor   int // oldest fragment requested in previous window
+}
+
+// touch records client request of fragment at now
+func (m *match) touch(fragment int, now time.Time, window time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	v := &m.views
+	v.last = now
+	if now.Sub(v.windowStart) > window {
+		v.prevFloor = v.floor
+		v.windowStart = now
+		v.floor = fragment
+		if v.prevFloor == 0 {
+			v.prevFloor = fragment
+		}
+		return
+	}
+	if fragment < v.floor {
+		v.floor = fragment
+	}
+}
+
+// lastActive returns last time match received data or was requested
+func (m *match) lastActive() time.Time {
+	m.RLock()
+	defer m.RUnlock()
+	if m.views.last.After(m.ReceiveAge) {
+		return m.views.last
+	}
+	return m.ReceiveAge
+}
+
+// protectedFloor returns oldest fragment which must not be evicted to keep memory budget.
+// Fragments viewers requested in the last two windows and the fragments /sync serves are protected while the match is live.
+func (m *match) protectedFloor(now time.Time, window time.Duration) int {
+	m.RLock()
+	defer m.RUnlock()
+	floor := m.Latest + 1 // nothing is protected
+	if now.Sub(m.ReceiveAge) <= window {
+		floor = m.Latest - gotv.SyncLatestOffset
+	}
+	if now.Sub(m.views.last) <= window {
+		if m.views.floor < floor {
+			floor = m.views.floor
+		}
+		if m.views.prevFloor < floor {
+			floor = m.views.prevFloor
+		}
+	}
+	return floor
+}
+
+// totalSize returns bytes used by every match
+func (m *InMemory) totalSize() int64 {
+	var total int64
+	for _, match := range m.match {
+		total += match.Size
+	}
+	return total
+}
+
+// enforceBudget evicts fragments of least recently active matches until total size fits the memory budget.
+// Start frames and fragments viewers need are never evicted, so usage can stay over budget.
+func (m *InMemory) enforceBudget(now time.Time) []Eviction {
+	if m.memoryBudget <= 0 {
+		return nil
+	}
+	total := m.totalSize()
+	if total <= m.memoryBudget {
+		return nil
+	}
+
+	type candidate struct {
+		token      string
+		match      *match
+		lastActive time.Time
+	}
+	candidates := make([]candidate, 0, len(m.match))
+	for token, match := range m.match {
+		candidates = append(candidates, candidate{token: token, match: match, lastActive: match.lastActive()})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastActive.Before(candidates[j].lastActive)
+	})
+
+	evicted := []Eviction{}
+	for _, c := range candidates {
+		floor := c.match.protectedFloor(now, m.viewerWindow)
+		fragments := make([]int, 0, len(c.match.Fragments))
+		for fragment := range c.match.Fragments {
+			if fragment < floor {
+				fragments = append(fragments, fragment)
+			}
+		}
+		sort.Ints(fragments)
+		for _, fragment := range fragments {
+			if total <= m.memoryBudget {
+				return evicted
+			}
+			b := c.match.deleteFragment(fragment)
+			total -= b
+			evicted = append(evicted, Eviction{Token: c.token, Fragment: fragment, Bytes: b, Reason: EvictionBudget})
+		}
+	}
+	return evicted
+}
+
+// notifyEvictions calls eviction callback. Must be called without holding the lock.
+func (m *InMemory) notifyEvictions(evicted *[]Eviction) {
+	if m.onEvict == nil {
+		return
+	}
+	for _, e := range *evicted {
+		m.onEvict(e)
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -13,27 +18,64 @@ import (
 )
 
 var (
-	auth string
-	port int
+	auth     string
+	admin    string
+	port     int
+	retain   int
+	idle     time.Duration
+	budget   int64
+	delay    time.Duration
+	snapshot string
 )
 
 func main() {
 	flag.StringVar(&auth, "auth", "SuperSecureStringDoNotShare", "tv_broadcast_origin_auth \"SuperSecureStringDoNotShare\"")
+	flag.StringVar(&admin, "admin", "", "X-Admin-Auth for admin routes under /admin. Admin routes are disabled if empty")
 	flag.IntVar(&port, "port", 8080, "Port to listen")
+	flag.IntVar(&retain, "retain", 0, "Fragments to keep per match. 0 keeps every fragment")
+	flag.DurationVar(&idle, "idle", 0, "Evict matches idle longer than this. 0 never evicts")
+	flag.Int64Var(&budget, "budget", 0, "Memory budget in bytes for every match. 0 is unlimited")
+	flag.DurationVar(&delay, "delay", 0, "Broadcast delay like tv_delay, e.g. 90s")
+	flag.StringVar(&snapshot, "snapshot", "", "Snapshot file loaded at boot and saved on shutdown. Disabled if empty")
 	flag.Parse()
 
-	m := inmemory.NewInmemoryGOTV(auth)
+	m := inmemory.NewInmemoryGOTV(auth, inmemory.WithRetainFragments(retain), inmemory.WithIdleTimeout(idle), inmemory.WithMemoryBudget(budget), inmemory.WithDelay(delay), inmemory.WithSnapshotFile(snapshot))
+	defer m.Close()
+	if snapshot != "" {
+		if err := m.Load(); err != nil {
+			panic(err)
+		}
+	}
 	app := fiber.New()
 	g := app.Group("/gotv") // /gotv
 	g.Use(logger.New())
-	gotv.SetupStoreHandlersFiber(m, g)
-	gotv.SetupBroadcasterHandlersFiber(m, g)
+	gotv.SetupStoreHandlersFiber(gotv.WrapStore(m), g)
+	gotv.SetupBroadcasterHandlersFiber(gotv.WrapBroadcaster(m), g)
+	if admin != "" {
+		a := app.Group("/admin") // /admin
+		a.Use(logger.New())
+		gotv.SetupAdminHandlersFiber(m, gotv.AdminPassword(admin), a)
+	}
 
 	p := fmt.Sprintf("%s:%d", "", port)
 
+	// Shutdown server on signal
+	go func() {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		<-ctx.Done()
+		app.Shutdown()
+	}()
+
 	// Start server
 	log.Println("Start listening on:", p)
 	if err := app.Listen(p); err != nil {
 		panic(err)
 	}
+	if snapshot != "" {
+		if err := m.Save(context.Background()); err != nil {
+			panic(err)
+		}
+		log.Println("Saved snapshot to:", snapshot)
+	}
 }
@@ -1,6 +1,7 @@
 package inmemory
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -10,31 +11,53 @@ import (
 //
 // In-memory GOTV+ Engine example
 //
-// This example does not handle any delay, caching, or hidden option features.
+// This example does not handle any caching, or hidden option features.
 // It only gives you fragment client requested.
 
 var _ gotv.Store = (*InMemory)(nil)
 var _ gotv.Broadcaster = (*InMemory)(nil)
+var _ gotv.FragmentMetadataBroadcaster = (*InMemory)(nil)
+var _ gotv.SizeBroadcaster = (*InMemory)(nil)
+var _ gotv.Deleter = (*InMemory)(nil)
+var _ gotv.DelayBroadcaster = (*InMemory)(nil)
+var _ gotv.MatchInfoBroadcaster = (*InMemory)(nil)
 
 // InMemory RAM based GOTV+ Broadcasting Engine
 type InMemory struct {
 	sync.RWMutex
 	password string            // password is Engine-global
 	match    map[string]*match // key=token value=match
-	delay    int               // frag delay
+
+	delay           time.Duration            // broadcast delay of every match
+	delays          map[string]time.Duration // key=token value=broadcast delay overriding delay
+	retainFragments int                      // fragments to keep per match
+	retainDuration  time.Duration            // duration to keep fragments
+	idleTimeout     time.Duration            // duration to keep idle matches
+	janitorInterval time.Duration
+	memoryBudget    int64         // bytes of every match
+	viewerWindow    time.Duration // duration a match has active viewers after the last request
+	onEvict         func(e Eviction)
+	snapshotFile    string
+	janitorStop     chan struct{}
+	janitorDone     chan struct{}
+	closeOnce       sync.Once
 }
 
 // match SYNC should NOT belong to match
 type match struct {
 	sync.RWMutex
-	ReceiveAge     time.Time
-	Latest         int
-	SignupFragment int
-	TickPerSecond  float64
-	Protocol       int
-	Start          map[int]*gotv.StartFrame // key=fragment_number
-	Fragments      map[int]*gotv.Fragment   // key=fragment_number
-	Map            string
+	ReceiveAge       time.Time
+	Latest           int
+	SignupFragment   int
+	TickPerSecond    float64
+	KeyframeInterval float64 // from start request, or estimated from full fragments
+	Protocol         int
+	Start            map[int]*gotv.StartFrame // key=fragment_number
+	Fragments        map[int]*gotv.Fragment   // key=fragment_number
+	Map              string
+	Size             int64         // bytes of Start and Fragments
+	Delay            time.Duration // broadcast delay
+	views            views
 }
 
 func (m *InMemory) newMatchIfEmpty(token string) {
@@ -48,10 +71,51 @@ func (m *InMemory) newMatchIfEmpty(token string) {
 			Start:          map[int]*gotv.StartFrame{},
 			Fragments:      map[int]*gotv.Fragment{},
 			Map:            "",
+			Delay:          m.matchDelay(token),
 		}
 	}
 }
 
+// matchDelay returns broadcast delay of token
+func (m *InMemory) matchDelay(token string) time.Duration {
+	if d, ok := m.delays[token]; ok {
+		return d
+	}
+	return m.delay
+}
+
+// GetMatchInfo implements gotv.MatchInfoBroadcaster
+func (m *InMemory) GetMatchInfo(ctx context.Context, token string) (gotv.MatchInfo, error) {
+	m.RLock()
+	defer m.RUnlock()
+	match, ok := m.match[token]
+	if !ok {
+		return gotv.MatchInfo{}, gotv.ErrMatchNotFound
+	}
+	return match.MatchInfo(), nil
+}
+
+// GetMatchDelay implements gotv.DelayBroadcaster
+func (m *InMemory) GetMatchDelay(ctx context.Context, token string) (time.Duration, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if match, ok := m.match[token]; ok {
+		return match.Delay, nil
+	}
+	return m.matchDelay(token), nil
+}
+
+// SetMatchDelay implements gotv.DelayBroadcaster. It also applies to the match started later.
+func (m *InMemory) SetMatchDelay(ctx context.Context, token string, d time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	m.delays[token] = d
+	if match, ok := m.match[token]; ok {
+		match.Delay = d
+	}
+	return nil
+}
+
 func (m *InMemory) isMatchExist(token string) bool {
 	_, ok := m.match[token]
 	return ok
@@ -65,74 +129,56 @@ func (m *InMemory) Auth(token string, auth string) error {
 	return nil
 }
 
-func (m *InMemory) isSyncReady(token string, fragment int) bool {
-	match, ok := m.match[token]
-	if !ok {
-		return false
+// MatchInfo implements gotv.SyncSource
+func (m *match) MatchInfo() gotv.MatchInfo {
+	return gotv.MatchInfo{
+		SignupFragment:   m.SignupFragment,
+		Latest:           m.Latest,
+		ReceivedAt:       m.ReceiveAge,
+		TickPerSecond:    m.TickPerSecond,
+		KeyframeInterval: m.KeyframeInterval,
+		Map:              m.Map,
+		Protocol:         m.Protocol,
+		Delay:            m.Delay,
 	}
-	if _, ok := match.Fragments[fragment]; !ok {
-		return false
+}
+
+// FragmentInfo implements gotv.SyncSource
+func (m *match) FragmentInfo(fragment int) (gotv.FragmentInfo, bool) {
+	f, ok := m.Fragments[fragment]
+	if !ok {
+		return gotv.FragmentInfo{}, false
 	}
-	return true
+	return gotv.FragmentInfo{
+		At:       f.At,
+		Tick:     f.Tick,
+		EndTick:  f.EndTick,
+		Final:    f.Final,
+		HasFull:  f.Full != nil,
+		HasDelta: f.Delta != nil,
+	}, true
 }
 
 // GetSyncLatest implements gotv.Broadcaster
 func (m *InMemory) GetSyncLatest(token string) (gotv.Sync, error) {
-	m.RLock()
-	defer m.RUnlock()
-	if !m.isMatchExist(token) {
-		return gotv.Sync{}, gotv.ErrMatchNotFound
-	}
-	match, ok := m.match[token]
-	if !ok {
-		return gotv.Sync{}, gotv.ErrMatchNotFound
-	}
-	if !m.isSyncReady(token, match.Latest-m.delay) {
-		return gotv.Sync{}, gotv.ErrFragmentNotFound
-	}
-	return gotv.Sync{
-		Tick:             match.Fragments[match.Latest-m.delay].Tick,
-		Endtick:          match.Fragments[match.Latest-m.delay].EndTick,
-		RealTimeDelay:    time.Since(match.Fragments[match.Latest-m.delay].At).Seconds(),
-		ReceiveAge:       time.Since(match.ReceiveAge).Seconds(),
-		Fragment:         match.Latest - m.delay,
-		SignupFragment:   match.SignupFragment,
-		TickPerSecond:    int(match.TickPerSecond),
-		KeyframeInterval: 3, // ?
-		// TokenRedirect:    "token/" + token,
-		Map:      match.Map,
-		Protocol: match.Protocol,
-	}, nil
+	return m.GetSync(token, 0)
 }
 
 // GetSync implements gotv.Broadcaster
 func (m *InMemory) GetSync(token string, fragment int) (gotv.Sync, error) {
 	m.RLock()
 	defer m.RUnlock()
-	if !m.isMatchExist(token) {
-		return gotv.Sync{}, gotv.ErrMatchNotFound
-	}
 	match, ok := m.match[token]
 	if !ok {
 		return gotv.Sync{}, gotv.ErrMatchNotFound
 	}
-	if !m.isSyncReady(token, fragment) {
-		return gotv.Sync{}, gotv.ErrFragmentNotFound
-	}
 	now := time.Now()
-	return gotv.Sync{
-		Tick:             match.Fragments[fragment].Tick,
-		Endtick:          match.Fragments[fragment].EndTick,
-		RealTimeDelay:    (now.Sub(match.Fragments[fragment].At)).Seconds(),
-		ReceiveAge:       (now.Sub(match.ReceiveAge)).Seconds(),
-		Fragment:         fragment,
-		SignupFragment:   match.SignupFragment,
-		TickPerSecond:    int(match.TickPerSecond),
-		KeyframeInterval: 3, // ?
-		// TokenRedirect:    token,
-		Map:      match.Map,
-		Protocol: match.Protocol,
-	}, nil
+	s, err := gotv.SelectSync(match, fragment, now)
+	if err != nil {
+		return gotv.Sync{}, err
+	}
+	match.touch(s.Fragment, now, m.viewerWindow)
+	return s, nil
 }
 
 // GetDelta implements gotv.Broadcaster
@@ -147,9 +193,11 @@ func (m *InMemory) GetDelta(token string, fragment int) ([]byte, error) {
 		return nil, gotv.ErrMatchNotFound
 	}
 	b, ok := match.Fragments[fragment]
-	if !ok {
-		return nil, gotv.ErrMatchNotFound
+	// full is received first, and delta is not received yet
+	if !ok || b.Delta == nil {
+		return nil, gotv.ErrFragmentNotFound
 	}
+	match.touch(fragment, time.Now(), m.viewerWindow)
 	return b.Delta, nil
 }
 
@@ -165,45 +213,94 @@ func (m *InMemory) GetFull(token string, fragment int) ([]byte, error) {
 		return nil, gotv.ErrMatchNotFound
 	}
 	b, ok := match.Fragments[fragment]
-	if !ok {
-		return nil, gotv.ErrMatchNotFound
+	if !ok || b.Full == nil {
+		return nil, gotv.ErrFragmentNotFound
 	}
+	match.touch(fragment, time.Now(), m.viewerWindow)
 	return b.Full, nil
 }
 
+// GetFragmentMetadata implements gotv.FragmentMetadataBroadcaster
+func (m *InMemory) GetFragmentMetadata(ctx context.Context, token string, fragment int) (gotv.FragmentMetadata, error) {
+	m.RLock()
+	defer m.RUnlock()
+	match, ok := m.match[token]
+	if !ok {
+		return gotv.FragmentMetadata{}, gotv.ErrMatchNotFound
+	}
+	f, ok := match.Fragments[fragment]
+	if !ok {
+		return gotv.FragmentMetadata{}, gotv.ErrFragmentNotFound
+	}
+	return gotv.FragmentMetadata{
+		Tick:      f.Tick,
+		EndTick:   f.EndTick,
+		Final:     f.Final,
+		Timestamp: f.At.UnixMilli(),
+		Full:      len(f.Full),
+		Delta:     len(f.Delta),
+	}, nil
+}
+
+// GetMatchSize implements gotv.SizeBroadcaster
+func (m *InMemory) GetMatchSize(ctx context.Context, token string) (gotv.MatchSize, error) {
+	m.RLock()
+	defer m.RUnlock()
+	match, ok := m.match[token]
+	if !ok {
+		return gotv.MatchSize{}, gotv.ErrMatchNotFound
+	}
+	return gotv.MatchSize{
+		Bytes:     match.Size,
+		Fragments: len(match.Fragments),
+	}, nil
+}
+
 // GetStart implements gotv.Broadcaster
 func (m *InMemory) GetStart(token string, fragment int) ([]byte, error) {
 	m.RLock()
 	defer m.RUnlock()
-	if !m.isMatchExist(token) {
-		return nil, gotv.ErrFragmentNotFound
-	}
 	match, ok := m.match[token]
 	if !ok {
 		return nil, gotv.ErrMatchNotFound
 	}
+	if fragment != match.SignupFragment {
+		return nil, gotv.ErrStartExpired
+	}
 	b, ok := match.Start[fragment]
 	if !ok {
-		return nil, gotv.ErrMatchNotFound
+		return nil, gotv.ErrFragmentNotFound
 	}
+	match.touch(fragment, time.Now(), m.viewerWindow)
 	return b.Body, nil
 }
 
 // OnStart implements gotv.Store
 func (m *InMemory) OnStart(token string, fragment int, f gotv.StartFrame) error {
+	var evicted []Eviction
+	defer m.notifyEvictions(&evicted)
 	m.Lock()
 	defer m.Unlock()
 	m.newMatchIfEmpty(token)
+	if old, ok := m.match[token].Start[fragment]; ok {
+		m.match[token].Size -= int64(len(old.Body))
+	}
 	m.match[token].Start[fragment] = &f
+	m.match[token].Size += int64(len(f.Body))
 	m.match[token].SignupFragment = fragment
 	m.match[token].TickPerSecond = f.Tps
+	m.match[token].KeyframeInterval = f.KeyframeInterval
 	m.match[token].Protocol = f.Protocol
 	m.match[token].Map = f.Map
+	m.match[token].ReceiveAge = time.Now()
+	evicted = m.enforceBudget(time.Now())
 	return nil
 }
 
 // OnFull implements gotv.Store
 func (m *InMemory) OnFull(token string, fragment int, tick int, at time.Time, b []byte) error {
+	var evicted []Eviction
+	defer m.notifyEvictions(&evicted)
 	m.Lock()
 	defer m.Unlock()
 	if !m.isMatchExist(token) {
@@ -214,14 +311,19 @@ func (m *InMemory) OnFull(token string, fragment int, tick int, at time.Time, b
 	}
 	m.match[token].Fragments[fragment].At = at
 	m.match[token].Fragments[fragment].Tick = tick
+	m.match[token].Size += int64(len(b) - len(m.match[token].Fragments[fragment].Full))
 	m.match[token].Fragments[fragment].Full = b
 	m.match[token].Latest = fragment
+	m.match[token].estimateKeyframeInterval(fragment)
 	m.match[token].ReceiveAge = time.Now()
+	evicted = m.enforceBudget(time.Now())
 	return nil
 }
 
 // OnDelta implements gotv.Store
 func (m *InMemory) OnDelta(token string, fragment int, endtick int, at time.Time, final bool, b []byte) error {
+	var evicted []Eviction
+	defer m.notifyEvictions(&evicted)
 	m.Lock()
 	defer m.Unlock()
 	if !m.isMatchExist(token) {
@@ -230,18 +332,94 @@ func (m *InMemory) OnDelta(token string, fragment int, endtick int, at time.Time
 	if m.match[token].Fragments[fragment] == nil {
 		m.match[token].Fragments[fragment] = &gotv.Fragment{}
 	}
+	if m.match[token].Fragments[fragment].At.IsZero() {
+		m.match[token].Fragments[fragment].At = at
+	}
 	m.match[token].Fragments[fragment].EndTick = endtick
 	m.match[token].Fragments[fragment].Final = final
+	m.match[token].Size += int64(len(b) - len(m.match[token].Fragments[fragment].Delta))
 	m.match[token].Fragments[fragment].Delta = b
+	evicted = m.enforceBudget(time.Now())
 	return nil
 }
 
-// NewInmemoryGOTV Get new pointer of inMemory GOTV+ Engine
-func NewInmemoryGOTV(password string) *InMemory {
-	return &InMemory{
-		RWMutex:  sync.RWMutex{},
-		password: password,
-		match:    map[string]*match{},
-		delay:    8,
+// DeleteMatch implements gotv.Deleter
+func (m *InMemory) DeleteMatch(ctx context.Context, token string) error {
+	m.Lock()
+	defer m.Unlock()
+	if !m.isMatchExist(token) {
+		return gotv.ErrMatchNotFound
+	}
+	delete(m.match, token)
+	delete(m.delays, token)
+	return nil
+}
+
+// DeleteFragment implements gotv.Deleter
+func (m *InMemory) DeleteFragment(ctx context.Context, token string, fragment int) error {
+	m.Lock()
+	defer m.Unlock()
+	match, ok := m.match[token]
+	if !ok {
+		return gotv.ErrMatchNotFound
+	}
+	if _, ok := match.Fragments[fragment]; !ok {
+		return gotv.ErrFragmentNotFound
+	}
+	match.deleteFragment(fragment)
+	return nil
+}
+
+// estimateKeyframeInterval estimates keyframe interval from fragment and the previous one
+// unless game server sent it in start request
+func (m *match) estimateKeyframeInterval(fragment int) {
+	if f, ok := m.Start[m.SignupFragment]; ok && f.KeyframeInterval > 0 {
+		return
+	}
+	prev, ok := m.Fragments[fragment-1]
+	if !ok || prev.Full == nil {
+		return
+	}
+	cur := m.Fragments[fragment]
+	if k := gotv.EstimateKeyframeInterval(
+		gotv.FragmentInfo{At: prev.At, Tick: prev.Tick},
+		gotv.FragmentInfo{At: cur.At, Tick: cur.Tick},
+		m.TickPerSecond,
+	); k > 0 {
+		m.KeyframeInterval = k
+	}
+}
+
+// deleteFragment deletes fragment and returns its size
+func (m *match) deleteFragment(fragment int) int64 {
+	f, ok := m.Fragments[fragment]
+	if !ok {
+		return 0
+	}
+	b := int64(len(f.Full) + len(f.Delta))
+	m.Size -= b
+	delete(m.Fragments, fragment)
+	return b
+}
+
+// NewInmemoryGOTV Get new pointer of inMemory GOTV+ Engine.
+// If any retention option is set, janitor goroutine is started. Call Close to stop it.
+func NewInmemoryGOTV(password string, opts ...Option) *InMemory {
+	m := &InMemory{
+		RWMutex:         sync.RWMutex{},
+		password:        password,
+		match:           map[string]*match{},
+		delays:          map[string]time.Duration{},
+		janitorInterval: defaultJanitorInterval,
+		viewerWindow:    defaultViewerWindow,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.hasRetention() {
+		m.janitorStop = make(chan struct{})
+		m.janitorDone = make(chan struct{})
+		go m.janitor()
 	}
+	return m
 }
@@ -0,0 +1,281 @@
+package inmemory_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+func TestRetention(t *testing.T) {
+	asserts := assert.New(t)
+	m := inmemory.NewInmemoryGOTV("gopher",
+		inmemory.WithRetainFragments(3),
+		inmemory.WithJanitorInterval(10*time.Millisecond),
+	)
+	defer m.Close()
+
+	now := time.Now()
+	asserts.NoError(m.OnStart("match", 1, gotv.StartFrame{At: now, Tps: 128, Body: []byte("start")}))
+	for i := 1; i <= 10; i++ {
+		asserts.NoError(m.OnFull("match", i, i*128, now, []byte("full")))
+		asserts.NoError(m.OnDelta("match", i, (i+1)*128, now, false, []byte("delta")))
+	}
+
+	asserts.Eventually(func() bool {
+		_, err := m.GetFull("match", 7)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	for i := 8; i <= 10; i++ {
+		_, err := m.GetFull("match", i)
+		asserts.NoError(err)
+	}
+	b, err := m.GetStart("match", 1)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start"), b)
+
+	// client asking for an evicted fragment is moved to the oldest retained one
+	s, err := m.GetSync("match", 1)
+	asserts.NoError(err)
+	asserts.Equal(8, s.Fragment)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	asserts := assert.New(t)
+	m := inmemory.NewInmemoryGOTV("gopher",
+		inmemory.WithIdleTimeout(50*time.Millisecond),
+		inmemory.WithJanitorInterval(10*time.Millisecond),
+	)
+	asserts.NoError(m.OnStart("match", 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")}))
+	asserts.NoError(m.SetMatchDelay(context.Background(), "match", time.Second))
+	// viewers keep the match after the game server stopped sending
+	for i := 0; i < 10; i++ {
+		_, err := m.GetStart("match", 1)
+		asserts.NoError(err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	asserts.Eventually(func() bool {
+		// GetMatchSize does not count as a view
+		_, err := m.GetMatchSize(context.Background(), "match")
+		return err == gotv.ErrMatchNotFound
+	}, time.Second, 10*time.Millisecond)
+	// delay of evicted match does not apply to the token reused later
+	d, err := m.GetMatchDelay(context.Background(), "match")
+	asserts.NoError(err)
+	asserts.Zero(d)
+
+	asserts.NoError(m.Close())
+	asserts.NoError(m.Close())
+}
+
+func TestMemoryBudget(t *testing.T) {
+	asserts := assert.New(t)
+	evicted := []inmemory.Eviction{}
+	m := inmemory.NewInmemoryGOTV("gopher",
+		inmemory.WithMemoryBudget(1000),
+		inmemory.WithViewerWindow(20*time.Millisecond),
+		inmemory.WithEvictionCallback(func(e inmemory.Eviction) {
+			evicted = append(evicted, e)
+		}),
+	)
+	defer m.Close()
+
+	body := make([]byte, 50)
+	now := time.Now()
+	store := func(token string, from, to int) {
+		for i := from; i <= to; i++ {
+			asserts.NoError(m.OnFull(token, i, i*128, now, body))
+			asserts.NoError(m.OnDelta(token, i, (i+1)*128, now, false, body))
+		}
+	}
+
+	// "watched" has a viewer on fragment 1, "unwatched" has none and stopped receiving
+	asserts.NoError(m.OnStart("unwatched", 1, gotv.StartFrame{At: now, Tps: 128, Body: body}))
+	store("unwatched", 1, 4)
+	time.Sleep(30 * time.Millisecond)
+	asserts.NoError(m.OnStart("watched", 1, gotv.StartFrame{At: now, Tps: 128, Body: body}))
+	store("watched", 1, 4)
+	_, err := m.GetFull("watched", 1)
+	asserts.NoError(err)
+	asserts.Empty(evicted)
+
+	// 900 bytes stored. next 2 fragments push usage over the budget
+	store("watched", 5, 6)
+	asserts.NotEmpty(evicted)
+	for _, e := range evicted {
+		asserts.Equal("unwatched", e.Token)
+		asserts.Equal(inmemory.EvictionBudget, e.Reason)
+	}
+	for i := 1; i <= 6; i++ {
+		_, err := m.GetFull("watched", i)
+		asserts.NoError(err)
+	}
+	_, err = m.GetStart("unwatched", 1)
+	asserts.NoError(err)
+}
+
+func TestDelay(t *testing.T) {
+	asserts := assert.New(t)
+	m := inmemory.NewInmemoryGOTV("gopher", inmemory.WithDelay(30*time.Second))
+	defer m.Close()
+
+	now := time.Now()
+	asserts.NoError(m.OnStart("match", 1, gotv.StartFrame{At: now, Tps: 128, Body: []byte("start")}))
+	for i := 1; i <= 20; i++ {
+		// fragment 20 is received now, fragment 1 is received 57 seconds ago
+		at := now.Add(-time.Duration(20-i) * 3 * time.Second)
+		asserts.NoError(m.OnFull("match", i, i*128, at, []byte("full")))
+		asserts.NoError(m.OnDelta("match", i, (i+1)*128, at, false, []byte("delta")))
+	}
+
+	s, err := m.GetSyncLatest("match")
+	asserts.NoError(err)
+	asserts.Equal(10, s.Fragment)
+	s, err = m.GetSync("match", 15)
+	asserts.NoError(err)
+	asserts.Equal(10, s.Fragment)
+
+	// override per match at runtime
+	asserts.NoError(m.SetMatchDelay(context.Background(), "match", 0))
+	s, err = m.GetSyncLatest("match")
+	asserts.NoError(err)
+	asserts.Equal(13, s.Fragment)
+	s, err = m.GetSync("match", 15)
+	asserts.NoError(err)
+	asserts.Equal(15, s.Fragment)
+	// override is deleted with the match
+	asserts.NoError(m.DeleteMatch(context.Background(), "match"))
+	d, err := m.GetMatchDelay(context.Background(), "match")
+	asserts.NoError(err)
+	asserts.Equal(30*time.Second, d)
+}
+
+func TestKeyframeInterval(t *testing.T) {
+	asserts := assert.New(t)
+	m := inmemory.NewInmemoryGOTV("gopher")
+	defer m.Close()
+
+	now := time.Now()
+	asserts.NoError(m.OnStart("sent", 1, gotv.StartFrame{At: now, Tps: 128, KeyframeInterval: 4, Body: []byte("start")}))
+	asserts.NoError(m.OnStart("derived", 1, gotv.StartFrame{At: now, Tps: 128, Body: []byte("start")}))
+	for i := 1; i <= 10; i++ {
+		for _, token := range []string{"sent", "derived"} {
+			asserts.NoError(m.OnFull(token, i, i*384, now, []byte("full")))
+			asserts.NoError(m.OnDelta(token, i, (i+1)*384, now, false, []byte("delta")))
+		}
+	}
+
+	s, err := m.GetSyncLatest("sent")
+	asserts.NoError(err)
+	asserts.Equal(4.0, s.KeyframeInterval)
+	s, err = m.GetSyncLatest("derived")
+	asserts.NoError(err)
+	asserts.Equal(3.0, s.KeyframeInterval)
+}
+
+func TestSnapshot(t *testing.T) {
+	asserts := assert.New(t)
+	p := filepath.Join(t.TempDir(), "gotv.snapshot")
+	m := inmemory.NewInmemoryGOTV("gopher", inmemory.WithSnapshotFile(p))
+	defer m.Close()
+
+	now := time.Now().Add(-time.Minute)
+	asserts.NoError(m.OnStart("match", 1, gotv.StartFrame{At: now, Tps: 128, Map: "de_dust2", Protocol: 4, Body: []byte("start")}))
+	for i := 1; i <= 10; i++ {
+		asserts.NoError(m.OnFull("match", i, i*384, now, []byte("full")))
+		asserts.NoError(m.OnDelta("match", i, (i+1)*384, now, false, []byte("delta")))
+	}
+	asserts.NoError(m.SetMatchDelay(context.Background(), "match", time.Second))
+	expected, err := m.GetSync("match", 5)
+	asserts.NoError(err)
+	expectedSize, err := m.GetMatchSize(context.Background(), "match")
+	asserts.NoError(err)
+	asserts.NoError(m.Save(context.Background()))
+
+	restored := inmemory.NewInmemoryGOTV("gopher", inmemory.WithSnapshotFile(p))
+	defer restored.Close()
+	asserts.NoError(restored.Load())
+	s, err := restored.GetSync("match", 5)
+	asserts.NoError(err)
+	asserts.Equal(expected.Fragment, s.Fragment)
+	asserts.Equal(expected.Tick, s.Tick)
+	asserts.Equal(expected.KeyframeInterval, s.KeyframeInterval)
+	asserts.Equal(expected.Map, s.Map)
+	size, err := restored.GetMatchSize(context.Background(), "match")
+	asserts.NoError(err)
+	asserts.Equal(expectedSize, size)
+	b, err := restored.GetStart("match", 1)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start"), b)
+
+	// game server keeps sending to restored engine
+	asserts.NoError(restored.OnFull("match", 11, 11*384, now, []byte("full")))
+
+	asserts.ErrorIs(restored.Restore(strings.NewReader(`{"matches":{}}`)), inmemory.ErrInvalidSnapshot)
+	asserts.NoError(inmemory.NewInmemoryGOTV("gopher", inmemory.WithSnapshotFile(filepath.Join(t.TempDir(), "none"))).Load())
+}
+
+// blockWriter blocks the first Write until release is closed
+type blockWriter struct {
+	strings.Builder
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *blockWriter) Write(p []byte) (int, error) {
+	if w.writing != nil {
+		close(w.writing)
+		w.writing = nil
+		<-w.release
+	}
+	return w.Builder.Write(p)
+}
+
+func TestSnapshotDoesNotBlockIngest(t *testing.T) {
+	asserts := assert.New(t)
+	m := inmemory.NewInmemoryGOTV("gopher")
+	defer m.Close()
+	now := time.Now()
+	asserts.NoError(m.OnStart("match", 1, gotv.StartFrame{At: now, Tps: 128, Map: "de_dust2", Protocol: 4, Body: []byte("start")}))
+	asserts.NoError(m.OnFull("match", 1, 384, now, []byte("full")))
+
+	w := &blockWriter{writing: make(chan struct{}), release: make(chan struct{})}
+	writing := w.writing
+	done := make(chan error)
+	go func() {
+		done <- m.Snapshot(w)
+	}()
+	<-writing
+
+	// slow writer does not block game server
+	ingested := make(chan struct{})
+	go func() {
+		defer close(ingested)
+		asserts.NoError(m.OnDelta("match", 1, 768, now, false, []byte("delta")))
+		asserts.NoError(m.OnFull("match", 2, 768, now, []byte("full")))
+	}()
+	select {
+	case <-ingested:
+	case <-time.After(time.Second):
+		t.Fatal("ingest is blocked by snapshot")
+	}
+	close(w.release)
+	asserts.NoError(<-done)
+
+	// snapshot is taken before the fragments ingested while writing
+	restored := inmemory.NewInmemoryGOTV("gopher")
+	defer restored.Close()
+	asserts.NoError(restored.Restore(strings.NewReader(w.String())))
+	_, err := restored.GetFull("match", 1)
+	asserts.NoError(err)
+	_, err = restored.GetDelta("match", 1)
+	asserts.ErrorIs(err, gotv.ErrFragmentNotFound)
+	_, err = restored.GetFull("match", 2)
+	asserts.ErrorIs(err, gotv.ErrFragmentNotFound)
+}
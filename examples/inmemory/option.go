@@ -0,0 +1,75 @@
+package inmemory
+
+import (
+	"time"
+)
+
+// Option InMemory engine option
+type Option func(m *InMemory)
+
+// WithRetainFragments keeps only the last n fragments of each match. 0 keeps every fragment.
+func WithRetainFragments(n int) Option {
+	return func(m *InMemory) {
+		m.retainFragments = n
+	}
+}
+
+// WithRetainDuration keeps only fragments received within d. 0 keeps every fragment.
+func WithRetainDuration(d time.Duration) Option {
+	return func(m *InMemory) {
+		m.retainDuration = d
+	}
+}
+
+// WithIdleTimeout evicts whole match after no fragment is received and no viewer requests its fragments or /sync for d.
+// Finished match is kept while viewers are still watching it. 0 never evicts matches.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(m *InMemory) {
+		m.idleTimeout = d
+	}
+}
+
+// WithJanitorInterval sets how often the janitor evicts fragments and matches. Default is 10 seconds.
+func WithJanitorInterval(d time.Duration) Option {
+	return func(m *InMemory) {
+		m.janitorInterval = d
+	}
+}
+
+// WithMemoryBudget limits bytes of every match in the engine. 0 is unlimited.
+// When storing a fragment exceeds the budget, fragments of the least recently viewed or idle matches are evicted first.
+// Fragments active viewers need are never evicted.
+func WithMemoryBudget(bytes int64) Option {
+	return func(m *InMemory) {
+		m.memoryBudget = bytes
+	}
+}
+
+// WithViewerWindow sets how long a match is considered to have active viewers after the last request. Default is 30 seconds.
+func WithViewerWindow(d time.Duration) Option {
+	return func(m *InMemory) {
+		m.viewerWindow = d
+	}
+}
+
+// WithEvictionCallback sets callback which is called for every eviction. f is called without holding engine lock.
+func WithEvictionCallback(f func(e Eviction)) Option {
+	return func(m *InMemory) {
+		m.onEvict = f
+	}
+}
+
+// WithDelay delays /sync by d like tv_delay. Fragments received within d are never served as /sync fragment.
+// Use SetMatchDelay to override it per match.
+func WithDelay(d time.Duration) Option {
+	return func(m *InMemory) {
+		m.delay = d
+	}
+}
+
+// WithSnapshotFile sets file which Save writes and Load reads
+func WithSnapshotFile(p string) Option {
+	return func(m *InMemory) {
+		m.snapshotFile = p
+	}
+}
@@ -0,0 +1,71 @@
+package inmemory
+
+import (
+	"time"
+)
+
+// defaultJanitorInterval default interval of janitor
+const defaultJanitorInterval = 10 * time.Second
+
+// hasRetention reports whether janitor needs to run
+func (m *InMemory) hasRetention() bool {
+	return m.retainFragments > 0 || m.retainDuration > 0 || m.idleTimeout > 0
+}
+
+// janitor evicts fragments and matches periodically until Close is called
+func (m *InMemory) janitor() {
+	defer close(m.janitorDone)
+	t := time.NewTicker(m.janitorInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.janitorStop:
+			return
+		case now := <-t.C:
+			evicted := m.evict(now)
+			m.notifyEvictions(&evicted)
+		}
+	}
+}
+
+// evict evicts idle matches and fragments out of retention window
+func (m *InMemory) evict(now time.Time) []Eviction {
+	m.Lock()
+	defer m.Unlock()
+	evicted := []Eviction{}
+	for token, match := range m.match {
+		if m.idleTimeout > 0 && now.Sub(match.lastActive()) > m.idleTimeout {
+			delete(m.match, token)
+			delete(m.delays, token)
+			evicted = append(evicted, Eviction{Token: token, Bytes: match.Size, Reason: EvictionIdle})
+			continue
+		}
+		for fragment, f := range match.Fragments {
+			if (m.retainFragments > 0 && fragment <= match.Latest-m.retainFragments) ||
+				(m.retainDuration > 0 && now.Sub(f.At) > m.retainDuration) {
+				b := match.deleteFragment(fragment)
+				evicted = append(evicted, Eviction{Token: token, Fragment: fragment, Bytes: b, Reason: EvictionRetention})
+			}
+		}
+		// old start frames can never be served again. always preserve the signup start.
+		for fragment, s := range match.Start {
+			if fragment != match.SignupFragment {
+				match.Size -= int64(len(s.Body))
+				delete(match.Start, fragment)
+			}
+		}
+	}
+	return evicted
+}
+
+// Close stops the janitor. Close does not drop stored matches.
+func (m *InMemory) Close() error {
+	m.closeOnce.Do(func() {
+		if m.janitorStop == nil {
+			return
+		}
+		close(m.janitorStop)
+		<-m.janitorDone
+	})
+	return nil
+}
@@ -0,0 +1,197 @@
+package inmemory
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+	"golang.org/x/xerrors"
+)
+
+var _ gotv.Saver = (*InMemory)(nil)
+
+// snapshotMagic first bytes of snapshot, followed by snapshotVersion and gob encoded snapshot
+const snapshotMagic = "GOTV+SNP"
+
+// snapshotVersion version of snapshot container. Increment when snapshot is changed incompatibly.
+const snapshotVersion byte = 1
+
+// ErrInvalidSnapshot snapshot is not written by Snapshot, or written by incompatible version
+var ErrInvalidSnapshot = xerrors.New("Invalid Snapshot")
+
+// snapshot every match and per-match delay of InMemory
+type snapshot struct {
+	Matches map[string]*snapshotMatch
+	Delays  map[string]time.Duration
+}
+
+// snapshotMatch exported fields of match. Viewer state is not saved.
+type snapshotMatch struct {
+	ReceiveAge       time.Time
+	Latest           int
+	SignupFragment   int
+	TickPerSecond    float64
+	KeyframeInterval float64
+	Protocol         int
+	Map              string
+	Delay            time.Duration
+	Start            map[int]*gotv.StartFrame
+	Fragments        map[int]*gotv.Fragment
+}
+
+// copySnapshot copies every match under the lock. Fragment bodies are never modified once received, so they are shared.
+func (m *InMemory) copySnapshot() snapshot {
+	m.RLock()
+	defer m.RUnlock()
+	s := snapshot{
+		Matches: make(map[string]*snapshotMatch, len(m.match)),
+		Delays:  make(map[string]time.Duration, len(m.delays)),
+	}
+	for token, d := range m.delays {
+		s.Delays[token] = d
+	}
+	for token, match := range m.match {
+		sm := &snapshotMatch{
+			ReceiveAge:       match.ReceiveAge,
+			Latest:           match.Latest,
+			SignupFragment:   match.SignupFragment,
+			TickPerSecond:    match.TickPerSecond,
+			KeyframeInterval: match.KeyframeInterval,
+			Protocol:         match.Protocol,
+			Map:              match.Map,
+			Delay:            match.Delay,
+			Start:            make(map[int]*gotv.StartFrame, len(match.Start)),
+			Fragments:        make(map[int]*gotv.Fragment, len(match.Fragments)),
+		}
+		for n, f := range match.Start {
+			start := *f
+			sm.Start[n] = &start
+		}
+		for n, f := range match.Fragments {
+			fragment := *f
+			sm.Fragments[n] = &fragment
+		}
+		s.Matches[token] = sm
+	}
+	return s
+}
+
+// Snapshot writes every match to w. Matches are copied first, so ingest is not blocked while encoding and writing.
+func (m *InMemory) Snapshot(w io.Writer) error {
+	s := m.copySnapshot()
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(snapshotMagic); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(snapshotVersion); err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(bw).Encode(&s); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Restore reads matches written by Snapshot from r.
+// Matches in r replace matches with the same token, and other matches are kept.
+func (m *InMemory) Restore(r io.Reader) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return xerrors.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic || header[len(snapshotMagic)] != snapshotVersion {
+		return ErrInvalidSnapshot
+	}
+	s := snapshot{}
+	if err := gob.NewDecoder(br).Decode(&s); err != nil {
+		return xerrors.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	var evicted []Eviction
+	defer m.notifyEvictions(&evicted)
+	m.Lock()
+	defer m.Unlock()
+	for token, d := range s.Delays {
+		m.delays[token] = d
+	}
+	for token, sm := range s.Matches {
+		match := &match{
+			ReceiveAge:       sm.ReceiveAge,
+			Latest:           sm.Latest,
+			SignupFragment:   sm.SignupFragment,
+			TickPerSecond:    sm.TickPerSecond,
+			KeyframeInterval: sm.KeyframeInterval,
+			Protocol:         sm.Protocol,
+			Map:              sm.Map,
+			Delay:            sm.Delay,
+			Start:            sm.Start,
+			Fragments:        sm.Fragments,
+		}
+		// gob decodes empty map as nil
+		if match.Start == nil {
+			match.Start = map[int]*gotv.StartFrame{}
+		}
+		if match.Fragments == nil {
+			match.Fragments = map[int]*gotv.Fragment{}
+		}
+		for _, f := range match.Start {
+			match.Size += int64(len(f.Body))
+		}
+		for _, f := range match.Fragments {
+			match.Size += int64(len(f.Full) + len(f.Delta))
+		}
+		m.match[token] = match
+	}
+	evicted = m.enforceBudget(time.Now())
+	return nil
+}
+
+// Save implements gotv.Saver. It writes Snapshot to the file set by WithSnapshotFile.
+// Snapshot is written to temporary file and renamed, so crash while saving keeps the previous snapshot.
+func (m *InMemory) Save(ctx context.Context) error {
+	if m.snapshotFile == "" {
+		return xerrors.New("snapshot file is not set")
+	}
+	f, err := os.CreateTemp(filepath.Dir(m.snapshotFile), "."+filepath.Base(m.snapshotFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	err = m.Snapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), m.snapshotFile)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// Load restores snapshot from the file set by WithSnapshotFile. It does nothing if the file does not exist yet.
+func (m *InMemory) Load() error {
+	if m.snapshotFile == "" {
+		return xerrors.New("snapshot file is not set")
+	}
+	f, err := os.Open(m.snapshotFile)
+	if err != nil {
+		if xerrors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	return m.Restore(f)
+}
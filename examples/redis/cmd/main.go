@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/redis"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+var (
+	auth     string
+	port     int
+	addrs    string
+	password string
+	prefix   string
+	ttl      time.Duration
+	delay    time.Duration
+)
+
+func main() {
+	flag.StringVar(&auth, "auth", "SuperSecureStringDoNotShare", "tv_broadcast_origin_auth \"SuperSecureStringDoNotShare\"")
+	flag.IntVar(&port, "port", 8080, "Port to listen")
+	flag.StringVar(&addrs, "redis", "localhost:6379", "Comma separated Redis addresses. Multiple addresses connect to Redis Cluster")
+	flag.StringVar(&password, "redis-password", "", "Redis password")
+	flag.StringVar(&prefix, "prefix", "gotv:", "Key prefix")
+	flag.DurationVar(&ttl, "ttl", 0, "Expire fragments this long after they are written. 0 keeps every fragment")
+	flag.DurationVar(&delay, "delay", 0, "Broadcast delay like tv_delay, e.g. 90s")
+	flag.Parse()
+
+	c := goredis.NewUniversalClient(&goredis.UniversalOptions{
+		Addrs:    strings.Split(addrs, ","),
+		Password: password,
+	})
+	defer c.Close()
+
+	m := redis.NewRedisGOTV(c, auth, redis.WithPrefix(prefix), redis.WithTTL(ttl), redis.WithDelay(delay))
+	app := fiber.New()
+	g := app.Group("/gotv") // /gotv
+	g.Use(logger.New())
+	gotv.SetupStoreHandlersFiber(m, g)
+	gotv.SetupBroadcasterHandlersFiber(m, g)
+
+	p := fmt.Sprintf("%s:%d", "", port)
+
+	// Start server
+	log.Println("Start listening on:", p)
+	if err := app.Listen(p); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,30 @@
+package redis
+
+import (
+	"time"
+)
+
+// Option Redis engine option
+type Option func(r *Redis)
+
+// WithPrefix prefixes every key, e.g. "gotv:" which is the default
+func WithPrefix(prefix string) Option {
+	return func(r *Redis) {
+		r.prefix = prefix
+	}
+}
+
+// WithTTL expires fragments ttl after they are written, and matches ttl after their last write. 0 keeps every fragment.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Redis) {
+		r.ttl = ttl
+	}
+}
+
+// WithDelay delays /sync by d like tv_delay. Fragments received within d are never served as /sync fragment.
+// Use SetMatchDelay to override it per match.
+func WithDelay(d time.Duration) Option {
+	return func(r *Redis) {
+		r.delay = d
+	}
+}
@@ -0,0 +1,562 @@
+package redis
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+//
+// Redis GOTV+ Engine example
+//
+// Every relay sharing the Redis serves the same matches. Each match is stored in these keys:
+//
+//	<prefix>{<token>}:match        hash of match metadata, signup fragment, latest fragment, delay override, size and number of fragments
+//	<prefix>{<token>}:keys         sorted set of body and fragment keys of the match scored by expiry, for deletion
+//	<prefix>{<token>}:sizes        hash of bytes of each body key, to keep size of the match when the key expires
+//	<prefix>{<token>}:start        start fragment body of the current signup fragment
+//	<prefix>{<token>}:frag:<n>     hash of tick, endtick, final, time and sizes of fragment n
+//	<prefix>{<token>}:full:<n>     full fragment body
+//	<prefix>{<token>}:delta:<n>    delta fragment body
+//
+// Token is wrapped in hash tag, so keys of a match live in one slot of Redis Cluster and scripts can update them atomically.
+// Scripts only access keys passed in KEYS, so they also run on Redis Cluster.
+// With TTL, fragments expire TTL after they are written, and the match and its start fragment expire TTL after its last write.
+
+var _ gotv.StoreV2 = (*Redis)(nil)
+var _ gotv.BroadcasterV2 = (*Redis)(nil)
+var _ gotv.FragmentMetadataBroadcaster = (*Redis)(nil)
+var _ gotv.SizeBroadcaster = (*Redis)(nil)
+var _ gotv.Deleter = (*Redis)(nil)
+var _ gotv.DelayBroadcaster = (*Redis)(nil)
+var _ gotv.MatchInfoBroadcaster = (*Redis)(nil)
+
+// Redis Redis based GOTV+ Broadcasting Engine
+type Redis struct {
+	c        goredis.UniversalClient
+	password string        // password
+	prefix   string        // key prefix
+	ttl      time.Duration // retention of fragments. 0 keeps every fragment
+	delay    time.Duration // broadcast delay like tv_delay
+}
+
+// resizeScript is prepended to scripts writing body keys. resize records bytes of body key and updates size of the match by the difference.
+//
+// KEYS: match, keys, sizes, ...
+const resizeScript = `
+local function resize(key, size)
+	local old = tonumber(redis.call('HGET', KEYS[3], key) or '0')
+	redis.call('HSET', KEYS[3], key, size)
+	redis.call('HINCRBY', KEYS[1], 'size', size - old)
+end
+`
+
+// retainScript is appended to scripts writing KEYS[4:ARGV[3]] of the match. It records the keys in keys sorted set scored by expiry.
+// With TTL, it refreshes TTL of the written KEYS, and forgets keys which are expired by now and subtracts them from size of the match.
+//
+// KEYS: match, keys, sizes, start, ...
+// ARGV: ttl, at, number of written KEYS, fragment key prefix, ...
+const retainScript = `
+local ttl = tonumber(ARGV[1])
+local written = tonumber(ARGV[3])
+local expiry = '+inf'
+if ttl > 0 then
+	expiry = tonumber(ARGV[2]) + ttl
+end
+for i = 4, written do
+	redis.call('ZADD', KEYS[2], expiry, KEYS[i])
+end
+if ttl > 0 then
+	for i = 1, written do
+		redis.call('PEXPIRE', KEYS[i], ttl)
+	end
+	local cutoff = '(' .. (redis.call('HGET', KEYS[1], 'received_at') or '0')
+	for _, key in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', cutoff)) do
+		local size = redis.call('HGET', KEYS[3], key)
+		if size then
+			redis.call('HINCRBY', KEYS[1], 'size', -tonumber(size))
+			redis.call('HDEL', KEYS[3], key)
+		end
+		if string.sub(key, 1, string.len(ARGV[4])) == ARGV[4] then
+			redis.call('HINCRBY', KEYS[1], 'fragments', -1)
+		end
+	end
+	redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', cutoff)
+end
+return 1
+`
+
+// startScript sets start fragment and signup fragment of the match
+//
+// KEYS: match, keys, sizes, start
+// ARGV: ttl, at, 4, fragment key prefix, fragment, tps, keyframe_interval, map, protocol, body
+var startScript = goredis.NewScript(resizeScript + `
+redis.call('SET', KEYS[4], ARGV[10])
+resize(KEYS[4], string.len(ARGV[10]))
+redis.call('HSET', KEYS[1], 'signup', ARGV[5], 'start_at', ARGV[2], 'tps', ARGV[6], 'keyframe_interval', ARGV[7], 'keyframe_sent', ARGV[7] ~= '0' and '1' or '0', 'map', ARGV[8], 'protocol', ARGV[9])
+if tonumber(ARGV[2]) > tonumber(redis.call('HGET', KEYS[1], 'received_at') or '0') then
+	redis.call('HSET', KEYS[1], 'received_at', ARGV[2])
+end
+` + retainScript)
+
+// fullScript stores full fragment and raises latest fragment. It returns 0 if the match does not exist.
+// Keyframe interval is estimated from the previous fragment unless game server sent it in start request, like gotv.EstimateKeyframeInterval.
+//
+// KEYS: match, keys, sizes, start, frag, full, previous frag
+// ARGV: ttl, at, 6, fragment key prefix, fragment, tick, body
+var fullScript = goredis.NewScript(resizeScript + `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if redis.call('EXISTS', KEYS[5]) == 0 then
+	redis.call('HINCRBY', KEYS[1], 'fragments', 1)
+end
+redis.call('SET', KEYS[6], ARGV[7])
+resize(KEYS[6], string.len(ARGV[7]))
+redis.call('HSET', KEYS[5], 'at', ARGV[2], 'tick', ARGV[6], 'full', string.len(ARGV[7]))
+if tonumber(ARGV[5]) > tonumber(redis.call('HGET', KEYS[1], 'latest') or '0') then
+	redis.call('HSET', KEYS[1], 'latest', ARGV[5])
+end
+if tonumber(ARGV[2]) > tonumber(redis.call('HGET', KEYS[1], 'received_at') or '0') then
+	redis.call('HSET', KEYS[1], 'received_at', ARGV[2])
+end
+if redis.call('HGET', KEYS[1], 'keyframe_sent') ~= '1' then
+	local prev = redis.call('HMGET', KEYS[7], 'tick', 'at', 'full')
+	if prev[3] then
+		local tps = tonumber(redis.call('HGET', KEYS[1], 'tps') or '0')
+		local tick, at = tonumber(ARGV[6]), tonumber(ARGV[2])
+		local k = 0
+		if tps > 0 and tick > tonumber(prev[1] or '0') then
+			k = (tick - tonumber(prev[1] or '0')) / tps
+		elseif prev[2] and at > tonumber(prev[2]) then
+			k = (at - tonumber(prev[2])) / 1000
+		end
+		if k > 0 then
+			redis.call('HSET', KEYS[1], 'keyframe_interval', tostring(k))
+		end
+	end
+end
+` + retainScript)
+
+// deltaScript stores delta fragment. It returns 0 if the match does not exist.
+//
+// KEYS: match, keys, sizes, start, frag, delta
+// ARGV: ttl, at, 6, fragment key prefix, endtick, final, body
+var deltaScript = goredis.NewScript(resizeScript + `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if redis.call('EXISTS', KEYS[5]) == 0 then
+	redis.call('HINCRBY', KEYS[1], 'fragments', 1)
+end
+redis.call('SET', KEYS[6], ARGV[7])
+resize(KEYS[6], string.len(ARGV[7]))
+redis.call('HSETNX', KEYS[5], 'at', ARGV[2])
+redis.call('HSET', KEYS[5], 'endtick', ARGV[5], 'final', ARGV[6], 'delta', string.len(ARGV[7]))
+if tonumber(ARGV[2]) > tonumber(redis.call('HGET', KEYS[1], 'received_at') or '0') then
+	redis.call('HSET', KEYS[1], 'received_at', ARGV[2])
+end
+` + retainScript)
+
+// deleteFragmentScript deletes fragment and subtracts it from size of the match.
+// It returns -1 if the match does not exist, and 0 if the fragment does not exist.
+//
+// KEYS: match, keys, sizes, frag, full, delta
+var deleteFragmentScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+if redis.call('EXISTS', KEYS[4]) == 0 then
+	return 0
+end
+for i = 5, 6 do
+	local size = redis.call('HGET', KEYS[3], KEYS[i])
+	if size then
+		redis.call('HINCRBY', KEYS[1], 'size', -tonumber(size))
+		redis.call('HDEL', KEYS[3], KEYS[i])
+	end
+end
+redis.call('HINCRBY', KEYS[1], 'fragments', -1)
+redis.call('DEL', KEYS[4], KEYS[5], KEYS[6])
+redis.call('ZREM', KEYS[2], KEYS[4], KEYS[5], KEYS[6])
+return 1
+`)
+
+func (r *Redis) key(token string, kind string) string {
+	return r.prefix + "{" + token + "}:" + kind
+}
+func (r *Redis) frameKey(token string, kind string, fragment int) string {
+	return r.key(token, kind) + ":" + strconv.Itoa(fragment)
+}
+
+// matchKeys returns KEYS shared by scripts: match, keys, sizes and start
+func (r *Redis) matchKeys(token string) []string {
+	return []string{r.key(token, "match"), r.key(token, "keys"), r.key(token, "sizes"), r.key(token, "start")}
+}
+
+// retainArgs returns ARGV of retainScript for script writing first written KEYS
+func (r *Redis) retainArgs(token string, at time.Time, written int) []interface{} {
+	return []interface{}{r.ttl.Milliseconds(), at.UnixMilli(), written, r.key(token, "frag") + ":"}
+}
+
+// matchInfo reads match hash. It returns gotv.ErrMatchNotFound if the match does not exist.
+func (r *Redis) matchInfo(ctx context.Context, token string) (gotv.MatchInfo, map[string]string, error) {
+	h, err := r.c.HGetAll(ctx, r.key(token, "match")).Result()
+	if err != nil {
+		return gotv.MatchInfo{}, nil, err
+	}
+	if len(h) == 0 {
+		return gotv.MatchInfo{}, nil, gotv.ErrMatchNotFound
+	}
+	signup, _ := strconv.Atoi(h["signup"])
+	latest, _ := strconv.Atoi(h["latest"])
+	receivedAt, _ := strconv.ParseInt(h["received_at"], 10, 64)
+	tps, _ := strconv.ParseFloat(h["tps"], 64)
+	keyframeInterval, _ := strconv.ParseFloat(h["keyframe_interval"], 64)
+	protocol, _ := strconv.Atoi(h["protocol"])
+	delay := r.delay
+	if ms, err := strconv.ParseInt(h["delay"], 10, 64); err == nil {
+		delay = time.Duration(ms) * time.Millisecond
+	}
+	return gotv.MatchInfo{
+		SignupFragment:   signup,
+		Latest:           latest,
+		ReceivedAt:       time.UnixMilli(receivedAt),
+		TickPerSecond:    tps,
+		KeyframeInterval: keyframeInterval,
+		Map:              h["map"],
+		Protocol:         protocol,
+		Delay:            delay,
+	}, h, nil
+}
+
+// fragmentInfo reads fragment hash
+func (r *Redis) fragmentInfo(ctx context.Context, token string, fragment int) (gotv.FragmentInfo, gotv.FragmentMetadata, bool, error) {
+	h, err := r.c.HGetAll(ctx, r.frameKey(token, "frag", fragment)).Result()
+	if err != nil {
+		return gotv.FragmentInfo{}, gotv.FragmentMetadata{}, false, err
+	}
+	info, metadata, ok := parseFragment(h)
+	return info, metadata, ok, nil
+}
+
+// parseFragment parses fragment hash. ok is false if the fragment does not exist.
+func parseFragment(h map[string]string) (info gotv.FragmentInfo, metadata gotv.FragmentMetadata, ok bool) {
+	if len(h) == 0 {
+		return gotv.FragmentInfo{}, gotv.FragmentMetadata{}, false
+	}
+	at, _ := strconv.ParseInt(h["at"], 10, 64)
+	tick, _ := strconv.Atoi(h["tick"])
+	endtick, _ := strconv.Atoi(h["endtick"])
+	final, _ := strconv.ParseBool(h["final"])
+	full, hasFull := h["full"]
+	delta, hasDelta := h["delta"]
+	fullSize, _ := strconv.Atoi(full)
+	deltaSize, _ := strconv.Atoi(delta)
+	info = gotv.FragmentInfo{
+		At:       time.UnixMilli(at),
+		Tick:     tick,
+		EndTick:  endtick,
+		Final:    final,
+		HasFull:  hasFull,
+		HasDelta: hasDelta,
+	}
+	metadata = gotv.FragmentMetadata{
+		Tick:      tick,
+		EndTick:   endtick,
+		Final:     final,
+		Timestamp: at,
+		Full:      fullSize,
+		Delta:     deltaSize,
+	}
+	return info, metadata, true
+}
+
+// source gotv.SyncSource reading fragments from Redis on demand.
+// Fragments around the requested one are read in one pipeline, so walking back over broadcast delay costs one round-trip.
+type source struct {
+	ctx   context.Context
+	r     *Redis
+	token string
+	info  gotv.MatchInfo
+	frags map[int]*gotv.FragmentInfo // nil value for fragment which does not exist
+	err   error                      // first error while reading fragments
+}
+
+// window returns fragments to read on each side of requested fragment: fragments received within broadcast delay, and a few more
+func (s *source) window() int {
+	k := s.info.KeyframeInterval
+	if k <= 0 {
+		k = 3
+	}
+	return int(math.Ceil(s.info.Delay.Seconds()/k)) + 2
+}
+
+// prefetch reads fragments lo to hi in one pipeline
+func (s *source) prefetch(lo, hi int) error {
+	cmds := map[int]*goredis.MapStringStringCmd{}
+	if _, err := s.r.c.Pipelined(s.ctx, func(p goredis.Pipeliner) error {
+		for n := lo; n <= hi; n++ {
+			if _, ok := s.frags[n]; !ok {
+				cmds[n] = p.HGetAll(s.ctx, s.r.frameKey(s.token, "frag", n))
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for n, cmd := range cmds {
+		s.frags[n] = nil
+		if f, _, ok := parseFragment(cmd.Val()); ok {
+			s.frags[n] = &f
+		}
+	}
+	return nil
+}
+
+// MatchInfo implements gotv.SyncSource
+func (s *source) MatchInfo() gotv.MatchInfo {
+	return s.info
+}
+
+// FragmentInfo implements gotv.SyncSource
+func (s *source) FragmentInfo(n int) (gotv.FragmentInfo, bool) {
+	if _, ok := s.frags[n]; !ok && s.err == nil {
+		lo, hi := n-s.window(), n+s.window()
+		if lo < s.info.SignupFragment {
+			lo = s.info.SignupFragment
+		}
+		if hi > s.info.Latest {
+			hi = s.info.Latest
+		}
+		if lo > n {
+			lo = n
+		}
+		if hi < n {
+			hi = n
+		}
+		s.err = s.prefetch(lo, hi)
+	}
+	f := s.frags[n]
+	if f == nil {
+		return gotv.FragmentInfo{}, false
+	}
+	return *f, true
+}
+
+// getFrame reads fragment body
+func (r *Redis) getFrame(ctx context.Context, key string) ([]byte, error) {
+	b, err := r.c.Get(ctx, key).Bytes()
+	if err != nil {
+		if xerrors.Is(err, goredis.Nil) {
+			return nil, gotv.ErrFragmentNotFound
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// Auth implements gotv.StoreV2
+func (r *Redis) Auth(ctx context.Context, token string, auth string) error {
+	if auth != r.password {
+		return gotv.ErrInvalidAuth
+	}
+	return nil
+}
+
+// OnStart implements gotv.StoreV2
+func (r *Redis) OnStart(ctx context.Context, token string, fragment int, f gotv.StartFrame) error {
+	return startScript.Run(ctx, r.c,
+		r.matchKeys(token),
+		append(r.retainArgs(token, f.At, 4), fragment, strconv.FormatFloat(f.Tps, 'f', -1, 64), strconv.FormatFloat(f.KeyframeInterval, 'f', -1, 64), f.Map, f.Protocol, f.Body)...,
+	).Err()
+}
+
+// OnFull implements gotv.StoreV2
+func (r *Redis) OnFull(ctx context.Context, token string, fragment int, tick int, at time.Time, b []byte) error {
+	ok, err := fullScript.Run(ctx, r.c,
+		append(r.matchKeys(token), r.frameKey(token, "frag", fragment), r.frameKey(token, "full", fragment), r.frameKey(token, "frag", fragment-1)),
+		append(r.retainArgs(token, at, 6), fragment, tick, b)...,
+	).Bool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return gotv.ErrMatchNotFound
+	}
+	return nil
+}
+
+// OnDelta implements gotv.StoreV2
+func (r *Redis) OnDelta(ctx context.Context, token string, fragment int, endtick int, at time.Time, final bool, b []byte) error {
+	ok, err := deltaScript.Run(ctx, r.c,
+		append(r.matchKeys(token), r.frameKey(token, "frag", fragment), r.frameKey(token, "delta", fragment)),
+		append(r.retainArgs(token, at, 6), endtick, strconv.FormatBool(final), b)...,
+	).Bool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return gotv.ErrMatchNotFound
+	}
+	return nil
+}
+
+// GetSync implements gotv.BroadcasterV2
+func (r *Redis) GetSync(ctx context.Context, token string, fragment int) (gotv.Sync, error) {
+	info, _, err := r.matchInfo(ctx, token)
+	if err != nil {
+		return gotv.Sync{}, err
+	}
+	src := &source{ctx: ctx, r: r, token: token, info: info, frags: map[int]*gotv.FragmentInfo{}}
+	s, err := gotv.SelectSync(src, fragment, time.Now())
+	if src.err != nil {
+		return gotv.Sync{}, src.err
+	}
+	return s, err
+}
+
+// GetMatchInfo implements gotv.MatchInfoBroadcaster
+func (r *Redis) GetMatchInfo(ctx context.Context, token string) (gotv.MatchInfo, error) {
+	info, _, err := r.matchInfo(ctx, token)
+	return info, err
+}
+
+// GetMatchSize implements gotv.SizeBroadcaster
+func (r *Redis) GetMatchSize(ctx context.Context, token string) (gotv.MatchSize, error) {
+	_, h, err := r.matchInfo(ctx, token)
+	if err != nil {
+		return gotv.MatchSize{}, err
+	}
+	size, _ := strconv.ParseInt(h["size"], 10, 64)
+	fragments, _ := strconv.Atoi(h["fragments"])
+	return gotv.MatchSize{Bytes: size, Fragments: fragments}, nil
+}
+
+// GetMatchDelay implements gotv.DelayBroadcaster
+func (r *Redis) GetMatchDelay(ctx context.Context, token string) (time.Duration, error) {
+	info, _, err := r.matchInfo(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	return info.Delay, nil
+}
+
+// SetMatchDelay implements gotv.DelayBroadcaster. Override is kept in the match hash, so every relay applies it, also after new signup fragment.
+func (r *Redis) SetMatchDelay(ctx context.Context, token string, d time.Duration) error {
+	if _, _, err := r.matchInfo(ctx, token); err != nil {
+		return err
+	}
+	return r.c.HSet(ctx, r.key(token, "match"), "delay", d.Milliseconds()).Err()
+}
+
+// GetSyncLatest implements gotv.BroadcasterV2
+func (r *Redis) GetSyncLatest(ctx context.Context, token string) (gotv.Sync, error) {
+	return r.GetSync(ctx, token, 0)
+}
+
+// GetStart implements gotv.BroadcasterV2
+func (r *Redis) GetStart(ctx context.Context, token string, fragment int) ([]byte, error) {
+	// signup fragment and start are read in one transaction, so start of another signup fragment is never served
+	var signup *goredis.StringCmd
+	var start *goredis.StringCmd
+	if _, err := r.c.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+		signup = p.HGet(ctx, r.key(token, "match"), "signup")
+		start = p.Get(ctx, r.key(token, "start"))
+		return nil
+	}); err != nil && !xerrors.Is(err, goredis.Nil) {
+		return nil, err
+	}
+	n, err := signup.Int()
+	if err != nil {
+		if xerrors.Is(err, goredis.Nil) {
+			return nil, gotv.ErrMatchNotFound
+		}
+		return nil, err
+	}
+	if fragment != n {
+		return nil, gotv.ErrStartExpired
+	}
+	b, err := start.Bytes()
+	if err != nil {
+		if xerrors.Is(err, goredis.Nil) {
+			return nil, gotv.ErrFragmentNotFound
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// GetFull implements gotv.BroadcasterV2
+func (r *Redis) GetFull(ctx context.Context, token string, fragment int) ([]byte, error) {
+	return r.getFrame(ctx, r.frameKey(token, "full", fragment))
+}
+
+// GetDelta implements gotv.BroadcasterV2
+func (r *Redis) GetDelta(ctx context.Context, token string, fragment int) ([]byte, error) {
+	return r.getFrame(ctx, r.frameKey(token, "delta", fragment))
+}
+
+// GetFragmentMetadata implements gotv.FragmentMetadataBroadcaster
+func (r *Redis) GetFragmentMetadata(ctx context.Context, token string, fragment int) (gotv.FragmentMetadata, error) {
+	if _, _, err := r.matchInfo(ctx, token); err != nil {
+		return gotv.FragmentMetadata{}, err
+	}
+	_, metadata, ok, err := r.fragmentInfo(ctx, token, fragment)
+	if err != nil {
+		return gotv.FragmentMetadata{}, err
+	}
+	if !ok {
+		return gotv.FragmentMetadata{}, gotv.ErrFragmentNotFound
+	}
+	return metadata, nil
+}
+
+// DeleteMatch implements gotv.Deleter
+func (r *Redis) DeleteMatch(ctx context.Context, token string) error {
+	if _, _, err := r.matchInfo(ctx, token); err != nil {
+		return err
+	}
+	keys, err := r.c.ZRange(ctx, r.key(token, "keys"), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	keys = append(keys, r.matchKeys(token)...)
+	return r.c.Del(ctx, keys...).Err()
+}
+
+// DeleteFragment implements gotv.Deleter
+func (r *Redis) DeleteFragment(ctx context.Context, token string, fragment int) error {
+	n, err := deleteFragmentScript.Run(ctx, r.c,
+		append(r.matchKeys(token)[:3], r.frameKey(token, "frag", fragment), r.frameKey(token, "full", fragment), r.frameKey(token, "delta", fragment)),
+	).Int()
+	if err != nil {
+		return err
+	}
+	switch n {
+	case -1:
+		return gotv.ErrMatchNotFound
+	case 0:
+		return gotv.ErrFragmentNotFound
+	}
+	return nil
+}
+
+// NewRedisGOTV Get new pointer of Redis GOTV+ Engine
+func NewRedisGOTV(c goredis.UniversalClient, password string, opts ...Option) *Redis {
+	r := &Redis{
+		c:        c,
+		password: password,
+		prefix:   "gotv:",
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
@@ -0,0 +1,213 @@
+package redis_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/redis"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, *goredis.Client) {
+	mr := miniredis.RunT(t)
+	c := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { c.Close() })
+	return mr, c
+}
+
+func TestRedis(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	_, c := newMiniredis(t)
+	// ingest and serve run on different relays
+	ingest := redis.NewRedisGOTV(c, "gopher")
+	serve := redis.NewRedisGOTV(c, "gopher", redis.WithDelay(30*time.Second))
+
+	asserts.ErrorIs(ingest.Auth(ctx, "match", "invalid"), gotv.ErrInvalidAuth)
+	now := time.Now()
+	asserts.ErrorIs(ingest.OnFull(ctx, "match", 1, 384, now, []byte("full")), gotv.ErrMatchNotFound)
+	asserts.ErrorIs(ingest.OnDelta(ctx, "match", 1, 768, now, false, []byte("delta")), gotv.ErrMatchNotFound)
+	asserts.NoError(ingest.OnStart(ctx, "match", 1, gotv.StartFrame{At: now, Tps: 128, Map: "de_dust2", Protocol: 4, Body: []byte("start")}))
+	for i := 1; i <= 20; i++ {
+		// fragment 20 is received now, fragment 1 is received 57 seconds ago
+		at := now.Add(-time.Duration(20-i) * 3 * time.Second)
+		asserts.NoError(ingest.OnFull(ctx, "match", i, i*384, at, []byte("full")))
+		asserts.NoError(ingest.OnDelta(ctx, "match", i, (i+1)*384, at, i == 20, []byte("delta")))
+	}
+
+	for _, td := range []struct {
+		title    string
+		get      func() ([]byte, error)
+		expected []byte
+		err      error
+	}{
+		{title: "start", get: func() ([]byte, error) { return serve.GetStart(ctx, "match", 1) }, expected: []byte("start")},
+		{title: "expired start", get: func() ([]byte, error) { return serve.GetStart(ctx, "match", 2) }, err: gotv.ErrStartExpired},
+		{title: "unknown match start", get: func() ([]byte, error) { return serve.GetStart(ctx, "unknown", 1) }, err: gotv.ErrMatchNotFound},
+		{title: "full", get: func() ([]byte, error) { return serve.GetFull(ctx, "match", 3) }, expected: []byte("full")},
+		{title: "delta", get: func() ([]byte, error) { return serve.GetDelta(ctx, "match", 3) }, expected: []byte("delta")},
+		{title: "not received", get: func() ([]byte, error) { return serve.GetDelta(ctx, "match", 21) }, err: gotv.ErrFragmentNotFound},
+	} {
+		t.Run(td.title, func(t *testing.T) {
+			asserts := assert.New(t)
+			b, err := td.get()
+			if td.err != nil {
+				asserts.ErrorIs(err, td.err)
+				return
+			}
+			asserts.NoError(err)
+			asserts.Equal(td.expected, b)
+		})
+	}
+
+	// delay is honored, and keyframe interval is estimated from full fragments
+	s, err := serve.GetSyncLatest(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(10, s.Fragment)
+	asserts.Equal(10*384, s.Tick)
+	asserts.Equal(1, s.SignupFragment)
+	asserts.Equal(128, s.TickPerSecond)
+	asserts.Equal("de_dust2", s.Map)
+	asserts.Equal(3.0, s.KeyframeInterval)
+	s, err = serve.GetSync(ctx, "match", 15)
+	asserts.NoError(err)
+	asserts.Equal(10, s.Fragment)
+	s, err = ingest.GetSyncLatest(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(13, s.Fragment)
+	_, err = serve.GetSyncLatest(ctx, "unknown")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+
+	// per match delay set on one relay applies to every relay
+	asserts.NoError(ingest.SetMatchDelay(ctx, "match", 40*time.Second))
+	d, err := serve.GetMatchDelay(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(40*time.Second, d)
+	s, err = serve.GetSyncLatest(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(6, s.Fragment)
+	asserts.ErrorIs(ingest.SetMatchDelay(ctx, "unknown", time.Second), gotv.ErrMatchNotFound)
+
+	m, err := serve.GetFragmentMetadata(ctx, "match", 20)
+	asserts.NoError(err)
+	asserts.Equal(gotv.FragmentMetadata{Tick: 20 * 384, EndTick: 21 * 384, Final: true, Timestamp: now.UnixMilli(), Full: 4, Delta: 5}, m)
+
+	// start and 20 fragments of full and delta
+	size, err := serve.GetMatchSize(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(gotv.MatchSize{Bytes: 5 + 20*(4+5), Fragments: 20}, size)
+	_, err = serve.GetMatchSize(ctx, "unknown")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+
+	asserts.NoError(ingest.DeleteFragment(ctx, "match", 20))
+	_, err = serve.GetFull(ctx, "match", 20)
+	asserts.ErrorIs(err, gotv.ErrFragmentNotFound)
+	size, err = serve.GetMatchSize(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(gotv.MatchSize{Bytes: 5 + 19*(4+5), Fragments: 19}, size)
+	asserts.ErrorIs(ingest.DeleteFragment(ctx, "match", 20), gotv.ErrFragmentNotFound)
+	asserts.NoError(ingest.DeleteMatch(ctx, "match"))
+	_, err = serve.GetSyncLatest(ctx, "match")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+	keys, err := c.Keys(ctx, "*").Result()
+	asserts.NoError(err)
+	asserts.Empty(keys)
+}
+
+func TestRedisLatest(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	_, c := newMiniredis(t)
+	r := redis.NewRedisGOTV(c, "gopher", redis.WithPrefix("test:"))
+	now := time.Now()
+	asserts.NoError(r.OnStart(ctx, "match", 1, gotv.StartFrame{At: now, Tps: 128, KeyframeInterval: 3, Body: []byte("start")}))
+
+	// fragments arrive out of order from several ingest relays
+	wg := sync.WaitGroup{}
+	for i := 20; i >= 1; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			asserts.NoError(r.OnFull(ctx, "match", i, i*384, now, []byte("full")))
+			asserts.NoError(r.OnDelta(ctx, "match", i, (i+1)*384, now, false, []byte("delta")))
+		}(i)
+	}
+	wg.Wait()
+	asserts.NoError(r.OnFull(ctx, "match", 5, 5*384, now, []byte("fullfull")))
+	s, err := r.GetSyncLatest(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(13, s.Fragment)
+	asserts.Equal(3.0, s.KeyframeInterval)
+
+	// new signup fragment replaces start
+	asserts.NoError(r.OnStart(ctx, "match", 21, gotv.StartFrame{At: now, Tps: 128, KeyframeInterval: 3, Map: "de_inferno", Body: []byte("start2")}))
+	_, err = r.GetStart(ctx, "match", 1)
+	asserts.ErrorIs(err, gotv.ErrStartExpired)
+	b, err := r.GetStart(ctx, "match", 21)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start2"), b)
+	// rewritten full and start are counted once
+	size, err := r.GetMatchSize(ctx, "match")
+	asserts.NoError(err)
+	asserts.Equal(gotv.MatchSize{Bytes: 6 + 20*(4+5) + 4, Fragments: 20}, size)
+	// match, keys, sizes and start, and frag, full and delta of every fragment
+	keys, err := c.Keys(ctx, "test:{match}:*").Result()
+	asserts.NoError(err)
+	asserts.Len(keys, 4+20*3)
+}
+
+func TestRedisTTL(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	mr, c := newMiniredis(t)
+	r := redis.NewRedisGOTV(c, "gopher", redis.WithTTL(time.Minute))
+	now := time.Now()
+	asserts.NoError(r.OnStart(ctx, "match", 1, gotv.StartFrame{At: now, Tps: 128, Body: []byte("start")}))
+	asserts.NoError(r.OnFull(ctx, "match", 1, 384, now, []byte("full")))
+
+	mr.FastForward(40 * time.Second)
+	asserts.NoError(r.OnFull(ctx, "match", 2, 768, now, []byte("full")))
+	mr.FastForward(40 * time.Second)
+
+	// fragment 1 is expired, and the match is kept alive by fragment 2
+	_, err := r.GetFull(ctx, "match", 1)
+	asserts.ErrorIs(err, gotv.ErrFragmentNotFound)
+	b, err := r.GetFull(ctx, "match", 2)
+	asserts.NoError(err)
+	asserts.Equal([]byte("full"), b)
+
+	mr.FastForward(time.Minute)
+	_, err = r.GetSyncLatest(ctx, "match")
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+	asserts.Empty(mr.Keys())
+
+	// live match longer than TTL keeps its start fragment, and forgets expired fragment keys
+	asserts.NoError(r.OnStart(ctx, "live", 1, gotv.StartFrame{At: now, Tps: 128, KeyframeInterval: 3, Body: []byte("start")}))
+	for i := 1; i <= 60; i++ {
+		at := now.Add(time.Duration(i) * 3 * time.Second)
+		asserts.NoError(r.OnFull(ctx, "live", i, i*384, at, []byte("full")))
+		asserts.NoError(r.OnDelta(ctx, "live", i, (i+1)*384, at, false, []byte("delta")))
+		mr.FastForward(3 * time.Second)
+	}
+	b, err = r.GetStart(ctx, "live", 1)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start"), b)
+	_, err = r.GetFull(ctx, "live", 1)
+	asserts.ErrorIs(err, gotv.ErrFragmentNotFound)
+	keys, err := c.ZCard(ctx, "gotv:{live}:keys").Result()
+	asserts.NoError(err)
+	// start, and frag, full and delta of fragments received within TTL
+	asserts.LessOrEqual(keys, int64(1+21*3))
+	// forgotten fragments are not counted in size
+	size, err := r.GetMatchSize(ctx, "live")
+	asserts.NoError(err)
+	asserts.LessOrEqual(size.Fragments, 21)
+	asserts.Equal(int64(5+size.Fragments*(4+5)), size.Bytes)
+	asserts.Equal(keys, int64(1+size.Fragments*3))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+	"github.com/FlowingSPDG/gotv-plus-go/relay"
+)
+
+var (
+	upstream string
+	token    string
+	port     int
+	interval time.Duration
+	retain   int
+)
+
+func main() {
+	flag.StringVar(&upstream, "upstream", "", "URL of upstream match, e.g. \"http://upstream:8080/gotv/<token>\"")
+	flag.StringVar(&token, "token", "", "Token to serve the match as. Last path element of upstream if empty")
+	flag.IntVar(&port, "port", 8080, "Port to listen")
+	flag.DurationVar(&interval, "interval", time.Second, "Wait before polling fragment which is not ready yet")
+	flag.IntVar(&retain, "retain", 0, "Fragments to keep. 0 keeps every fragment")
+	flag.Parse()
+	if token == "" {
+		token = path.Base(upstream)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Edge relay serves only GET routes, so game server does not need to reach it
+	m := inmemory.NewInmemoryGOTV("", inmemory.WithRetainFragments(retain))
+	defer m.Close()
+	r := relay.New(upstream, token, gotv.WrapStore(m), relay.WithInterval(interval))
+	go func() {
+		if err := r.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			panic(err)
+		}
+	}()
+	go func() {
+		for range time.Tick(10 * time.Second) {
+			lag := r.Lag()
+			log.Printf("Relay lag: fragment=%d signup=%d behind=%s\n", lag.Fragment, lag.SignupFragment, lag.Duration)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/gotv/", http.StripPrefix("/gotv", gotv.NewHTTPHandler(nil, gotv.WrapBroadcaster(m)))) // /gotv
+	p := fmt.Sprintf("%s:%d", "", port)
+	srv := &http.Server{Addr: p, Handler: mux}
+
+	// Shutdown server on signal
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	// Start server
+	log.Println("Start listening on:", p)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		panic(err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/disk"
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+	"github.com/FlowingSPDG/gotv-plus-go/replay"
+)
+
+var (
+	dir    string
+	source string
+	token  string
+	port   int
+	speed  float64
+	offset time.Duration
+	loop   bool
+	retain int
+)
+
+func main() {
+	flag.StringVar(&dir, "dir", "gotv_plus_binary", "Directory of Disk engine to read the match from")
+	flag.StringVar(&source, "source", "", "Token of the stored match")
+	flag.StringVar(&token, "token", "", "Token to broadcast the replay as. \"<source>-replay\" if empty")
+	flag.IntVar(&port, "port", 8080, "Port to listen")
+	flag.Float64Var(&speed, "speed", 1, "Replay speed. 2 replays twice as fast")
+	flag.DurationVar(&offset, "offset", 0, "Match time to skip, e.g. 45m")
+	flag.BoolVar(&loop, "loop", false, "Replay again from the beginning after the last fragment")
+	flag.IntVar(&retain, "retain", 100, "Fragments to keep. 0 keeps every fragment")
+	flag.Parse()
+	if source == "" {
+		log.Fatalln("-source is required")
+	}
+	if token == "" {
+		token = source + "-replay"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d, err := disk.NewDiskGOTV("", dir)
+	if err != nil {
+		panic(err)
+	}
+	defer d.Close()
+	// Viewers watch the replay from memory, same as live match
+	m := inmemory.NewInmemoryGOTV("", inmemory.WithRetainFragments(retain))
+	defer m.Close()
+	opts := []replay.Option{replay.WithSpeed(speed), replay.WithOffset(offset)}
+	if loop {
+		opts = append(opts, replay.WithLoop())
+	}
+	r := replay.New(gotv.WrapBroadcaster(d), source, gotv.WrapStore(m), token, opts...)
+	go func() {
+		if err := r.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			panic(err)
+		}
+		log.Println("Replay finished:", token)
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/gotv/", http.StripPrefix("/gotv", gotv.NewHTTPHandler(nil, gotv.WrapBroadcaster(m)))) // /gotv
+	p := fmt.Sprintf("%s:%d", "", port)
+	srv := &http.Server{Addr: p, Handler: mux}
+
+	// Shutdown server on signal
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	// Start server
+	log.Printf("Start listening on: %s, playcast \"http://<IP-ADDRESS>%s/gotv/%s\"\n", p, p, token)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		panic(err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/FlowingSPDG/gotv-plus-go/blobstore"
+	"github.com/FlowingSPDG/gotv-plus-go/examples/s3"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+var (
+	auth         string
+	port         int
+	delay        time.Duration
+	endpoint     string
+	region       string
+	insecure     bool
+	bucket       string
+	prefix       string
+	storageClass string
+	cacheControl string
+	redirect     string
+)
+
+func main() {
+	flag.StringVar(&auth, "auth", "SuperSecureStringDoNotShare", "tv_broadcast_origin_auth \"SuperSecureStringDoNotShare\"")
+	flag.IntVar(&port, "port", 8080, "Port to listen")
+	flag.DurationVar(&delay, "delay", 0, "Broadcast delay like tv_delay, e.g. 90s")
+	flag.StringVar(&endpoint, "endpoint", "s3.amazonaws.com", "S3 compatible endpoint, e.g. \"localhost:9000\" for local MinIO")
+	flag.StringVar(&region, "region", "", "Region of the bucket. Looked up from the endpoint if empty")
+	flag.BoolVar(&insecure, "insecure", false, "Connect to the endpoint over plain HTTP")
+	flag.StringVar(&bucket, "bucket", "", "Bucket to store fragments")
+	flag.StringVar(&prefix, "prefix", "", "Object key prefix, e.g. \"gotv/\"")
+	flag.StringVar(&storageClass, "storage-class", "", "Storage class of objects, e.g. \"STANDARD\". Bucket default if empty")
+	flag.StringVar(&cacheControl, "cache-control", "", "Cache-Control of fragments, e.g. \"public, max-age=31536000, immutable\"")
+	flag.StringVar(&redirect, "redirect", "", "Redirect clients to this base URL of objects, e.g. \"https://<bucket>.s3.amazonaws.com\". Fragments are served through relay if empty")
+	flag.Parse()
+
+	// Credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or MINIO_ACCESS_KEY and MINIO_SECRET_KEY
+	s, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewChainCredentials([]credentials.Provider{&credentials.EnvAWS{}, &credentials.EnvMinio{}}),
+		Secure: !insecure,
+		Region: region,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	m := s3.NewS3GOTV(s, bucket, auth, delay, blobstore.WithPrefix(prefix), blobstore.WithStorageClass(storageClass), blobstore.WithCacheControl(cacheControl), blobstore.WithRedirect(redirect))
+	app := fiber.New()
+	g := app.Group("/gotv") // /gotv
+	g.Use(logger.New())
+	gotv.SetupStoreHandlersFiber(m, g)
+	gotv.SetupBroadcasterHandlersFiber(m, g)
+
+	p := fmt.Sprintf("%s:%d", "", port)
+
+	// Start server
+	log.Println("Start listening on:", p)
+	if err := app.Listen(p); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,128 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/FlowingSPDG/gotv-plus-go/blobstore"
+)
+
+//
+// S3 compatible object storage GOTV+ Engine example
+//
+// Bucket adapts S3 bucket to blobstore.BlobStore, and blobstore.Engine does the rest.
+// Objects are laid out same as GCS engine.
+//
+
+var _ blobstore.BlobStore = (*Bucket)(nil)
+
+// Bucket S3 bucket as blobstore.BlobStore
+type Bucket struct {
+	s      *minio.Client // works with AWS S3, MinIO, Cloudflare R2 and other S3 compatible storages
+	bucket string        // bucket name
+}
+
+// isNotExist reports whether err is returned for object which does not exist
+func isNotExist(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
+
+// lowerKeys returns metadata with lower case keys. S3 returns canonical header keys like "Tick".
+func lowerKeys(metadata map[string]string) map[string]string {
+	lowered := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		lowered[strings.ToLower(k)] = v
+	}
+	return lowered
+}
+
+// Put implements blobstore.BlobStore
+func (b *Bucket) Put(ctx context.Context, key string, r io.Reader, attrs blobstore.Attrs) (int64, error) {
+	// fragments are buffered to upload them in single PUT request with Content-Length.
+	// unknown length makes the client fall back to multipart upload.
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	info, err := b.s.PutObject(ctx, b.bucket, key, bytes.NewReader(body), int64(len(body)), minio.PutObjectOptions{
+		UserMetadata: attrs.Metadata,
+		ContentType:  attrs.ContentType,
+		CacheControl: attrs.CacheControl,
+		StorageClass: attrs.StorageClass,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// Get implements blobstore.BlobStore
+func (b *Bucket) Get(ctx context.Context, key string) (io.ReadCloser, blobstore.Info, error) {
+	o, err := b.s.GetObject(ctx, b.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, blobstore.Info{}, err
+	}
+	// object is requested lazily, and Stat sends the request
+	info, err := o.Stat()
+	if err != nil {
+		o.Close()
+		if isNotExist(err) {
+			return nil, blobstore.Info{}, blobstore.ErrNotExist
+		}
+		return nil, blobstore.Info{}, err
+	}
+	return o, blobstore.Info{Key: key, Size: info.Size, Modified: info.LastModified, Metadata: lowerKeys(info.UserMetadata)}, nil
+}
+
+// Delete implements blobstore.BlobStore
+func (b *Bucket) Delete(ctx context.Context, key string) error {
+	if err := b.s.RemoveObject(ctx, b.bucket, key, minio.RemoveObjectOptions{}); err != nil && !isNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List implements blobstore.BlobStore. Listing does not contain user metadata on S3, so each object is stat'ed if metadata is needed.
+func (b *Bucket) List(ctx context.Context, prefix string, metadata bool) ([]blobstore.Info, error) {
+	// cancelling ctx stops listing when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	infos := []blobstore.Info{}
+	for info := range b.s.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		if metadata {
+			stat, err := b.s.StatObject(ctx, b.bucket, info.Key, minio.StatObjectOptions{})
+			if err != nil {
+				return nil, err
+			}
+			info.UserMetadata = stat.UserMetadata
+		}
+		infos = append(infos, blobstore.Info{
+			Key:      info.Key,
+			Size:     info.Size,
+			Modified: info.LastModified,
+			Metadata: lowerKeys(info.UserMetadata),
+		})
+	}
+	return infos, nil
+}
+
+// NewBucket Get new pointer of S3 bucket adapter
+func NewBucket(s *minio.Client, bucket string) *Bucket {
+	return &Bucket{
+		s:      s,
+		bucket: bucket,
+	}
+}
+
+// NewS3GOTV Get new pointer of S3 GOTV+ Engine
+func NewS3GOTV(s *minio.Client, bucket string, password string, delay time.Duration, opts ...blobstore.Option) *blobstore.Engine {
+	return blobstore.New(NewBucket(s, bucket), password, delay, opts...)
+}
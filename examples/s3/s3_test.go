@@ -0,0 +1,194 @@
+package s3_test
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/blobstore"
+	"github.com/FlowingSPDG/gotv-plus-go/examples/s3"
+)
+
+// fakeObject object stored in fakeS3
+type fakeObject struct {
+	header   http.Header // Content-Type, Cache-Control, X-Amz-Storage-Class and X-Amz-Meta-*
+	data     []byte
+	modified time.Time
+}
+
+// fakeS3 minimal path-style S3 server which minio.Client uses for put, get, stat, list and delete
+type fakeS3 struct {
+	sync.Mutex
+	objects map[string]*fakeObject // key=bucket/key
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		type content struct {
+			Key          string
+			LastModified string
+			ETag         string
+			Size         int
+			StorageClass string
+		}
+		result := struct {
+			XMLName     xml.Name `xml:"ListBucketResult"`
+			Name        string
+			Prefix      string
+			KeyCount    int
+			IsTruncated bool
+			Contents    []content
+		}{Name: strings.TrimSuffix(path, "/"), Prefix: r.URL.Query().Get("prefix")}
+		for k, o := range f.objects {
+			key := strings.TrimPrefix(k, result.Name+"/")
+			if !strings.HasPrefix(k, result.Name+"/") || !strings.HasPrefix(key, result.Prefix) {
+				continue
+			}
+			result.Contents = append(result.Contents, content{
+				Key:          key,
+				LastModified: o.modified.UTC().Format(time.RFC3339Nano),
+				ETag:         `"etag"`,
+				Size:         len(o.data),
+				StorageClass: "STANDARD",
+			})
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		result.KeyCount = len(result.Contents)
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPut:
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		o := &fakeObject{header: http.Header{}, data: b, modified: time.Now()}
+		for k, v := range r.Header {
+			if strings.HasPrefix(k, "X-Amz-Meta-") || k == "Content-Type" || k == "Cache-Control" || k == "X-Amz-Storage-Class" {
+				o.header[k] = v
+			}
+		}
+		f.objects[path] = o
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		o, ok := f.objects[path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			}
+			return
+		}
+		for k, v := range o.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", o.modified.UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(o.data)))
+		if r.Method == http.MethodGet {
+			w.Write(o.data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+	}
+}
+
+func newFakeS3(t *testing.T) (*minio.Client, *fakeS3) {
+	f := &fakeS3{objects: map[string]*fakeObject{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	// anonymous client sends unsigned plain body
+	s, err := minio.New(u.Host, &minio.Options{Creds: credentials.NewStaticV4("", "", ""), Region: "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, f
+}
+
+func TestBucket(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	s, f := newFakeS3(t)
+	b := s3.NewBucket(s, "bucket")
+
+	_, _, err := b.Get(ctx, "gotv/match/1_full")
+	asserts.ErrorIs(err, blobstore.ErrNotExist)
+	asserts.NoError(b.Delete(ctx, "gotv/match/1_full"), "missing blob is ignored")
+
+	metadata := map[string]string{"tick": "384", "final": "true"}
+	n, err := b.Put(ctx, "gotv/match/1_full", strings.NewReader("full"), blobstore.Attrs{
+		ContentType:  "application/octet-stream",
+		CacheControl: "public, max-age=31536000, immutable",
+		StorageClass: "REDUCED_REDUNDANCY",
+		Metadata:     metadata,
+	})
+	asserts.NoError(err)
+	asserts.Equal(int64(4), n)
+	_, err = b.Put(ctx, "gotv/other/1_full", strings.NewReader("other"), blobstore.Attrs{})
+	asserts.NoError(err)
+
+	// attrs are sent as object headers, S3 stores metadata keys canonicalized like "X-Amz-Meta-Tick"
+	f.Lock()
+	o := f.objects["bucket/gotv/match/1_full"]
+	f.Unlock()
+	if asserts.NotNil(o) {
+		asserts.Equal("REDUCED_REDUNDANCY", o.header.Get("X-Amz-Storage-Class"))
+		asserts.Equal("public, max-age=31536000, immutable", o.header.Get("Cache-Control"))
+		asserts.Equal("384", o.header.Get("X-Amz-Meta-Tick"))
+	}
+
+	// metadata keys are folded back to lower case
+	r, info, err := b.Get(ctx, "gotv/match/1_full")
+	if asserts.NoError(err) {
+		body, err := io.ReadAll(r)
+		asserts.NoError(err)
+		asserts.NoError(r.Close())
+		asserts.Equal("full", string(body))
+		asserts.Equal("gotv/match/1_full", info.Key)
+		asserts.Equal(int64(4), info.Size)
+		asserts.Equal(metadata, info.Metadata)
+	}
+
+	// listing contains metadata only if requested
+	infos, err := b.List(ctx, "gotv/match/", true)
+	asserts.NoError(err)
+	if asserts.Len(infos, 1) {
+		asserts.Equal("gotv/match/1_full", infos[0].Key)
+		asserts.Equal(int64(4), infos[0].Size)
+		asserts.Equal(metadata, infos[0].Metadata)
+	}
+	infos, err = b.List(ctx, "gotv/match/", false)
+	asserts.NoError(err)
+	if asserts.Len(infos, 1) {
+		asserts.Equal("gotv/match/1_full", infos[0].Key)
+		asserts.Empty(infos[0].Metadata)
+	}
+
+	asserts.NoError(b.Delete(ctx, "gotv/match/1_full"))
+	_, _, err = b.Get(ctx, "gotv/match/1_full")
+	asserts.ErrorIs(err, blobstore.ErrNotExist)
+	infos, err = b.List(ctx, "gotv/", false)
+	asserts.NoError(err)
+	asserts.Len(infos, 1)
+}
@@ -0,0 +1,261 @@
+package fanout
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+var _ gotv.StoreV2 = (*Store)(nil)
+
+// ErrQueueFull write to Async child is dropped because its queue is full
+var ErrQueueFull = xerrors.New("Queue Full")
+
+// Policy how Store writes to a child
+type Policy struct {
+	Required bool          // error of the child fails the ingest request. Failure of best-effort child is only reported to error handler. Ignored for Async child.
+	Async    bool          // write in background through queue, so the child never delays the ingest request
+	Queue    int           // queue size of Async child. Writes except start are dropped while the queue is full. Default is 64.
+	Retries  int           // retries after the first failure
+	Backoff  time.Duration // wait between retries
+	Timeout  time.Duration // limit of each write to best-effort child including retries, so a stalled child delays the ingest request at most Timeout. Default is 5 seconds.
+}
+
+// Child downstream Store with its policy
+type Child struct {
+	Name   string // name reported to error handler and in Stats
+	Store  gotv.StoreV2
+	Policy Policy
+}
+
+// ChildStats counters of a child
+type ChildStats struct {
+	Name    string
+	Written int64 // writes succeeded
+	Failed  int64 // writes failed after every retry
+	Dropped int64 // writes dropped because queue of Async child was full
+	Queued  int   // writes waiting in queue of Async child
+}
+
+// write one of OnStart, OnFull and OnDelta
+type write func(ctx context.Context, s gotv.StoreV2) error
+
+// item queued write of Async child
+type item struct {
+	seq int64 // increases by every queued write
+	w   write
+}
+
+type child struct {
+	Child
+	sync.Mutex
+	queue      chan item
+	seq        int64 // seq of the last queued write
+	start      write // start which did not fit in the queue
+	startAfter int64 // start is written right after the queued write with this seq
+	written    int64
+	failed     int64
+	dropped    int64
+}
+
+// Store gotv.StoreV2 which forwards every ingest to child Stores.
+// Auth is checked by the first child.
+type Store struct {
+	children  []*child
+	onError   func(name string, err error)
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// do writes to c with retries
+func (s *Store) do(ctx context.Context, c *child, w write) error {
+	var err error
+	for i := 0; i <= c.Policy.Retries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.Policy.Backoff):
+			}
+		}
+		if err = w(ctx, c.Store); err == nil {
+			atomic.AddInt64(&c.written, 1)
+			return nil
+		}
+		// start is required first, and retrying does not help
+		if xerrors.Is(err, gotv.ErrMatchNotFound) {
+			break
+		}
+	}
+	atomic.AddInt64(&c.failed, 1)
+	if s.onError != nil {
+		s.onError(c.Name, err)
+	}
+	return err
+}
+
+// run writes queued writes of Async child until the queue is closed
+func (s *Store) run(c *child) {
+	defer s.wg.Done()
+	for it := range c.queue {
+		s.do(context.Background(), c, it.w)
+		c.Lock()
+		start := c.start
+		if start != nil && c.startAfter == it.seq {
+			c.start = nil
+		} else {
+			start = nil
+		}
+		c.Unlock()
+		if start != nil {
+			s.do(context.Background(), c, start)
+		}
+	}
+}
+
+// enqueue queues w to Async child without blocking.
+// Start is never dropped, because every following write fails on the child without it.
+// Start which does not fit in the full queue is kept aside, and written right after the writes queued before it.
+func (s *Store) enqueue(c *child, w write, start bool) {
+	c.Lock()
+	select {
+	case c.queue <- item{seq: c.seq + 1, w: w}:
+		c.seq++
+		c.Unlock()
+		return
+	default:
+	}
+	replaced := false
+	if start {
+		// queue is full, so the write with c.seq is still in the queue and run sees the start after it.
+		// Newer start replaces the start kept aside.
+		replaced = c.start != nil
+		c.start, c.startAfter = w, c.seq
+	}
+	c.Unlock()
+	if start && !replaced {
+		return
+	}
+	atomic.AddInt64(&c.dropped, 1)
+	if s.onError != nil {
+		s.onError(c.Name, ErrQueueFull)
+	}
+}
+
+// write writes to every child except Async children concurrently, and returns once they are written.
+// Best-effort children are written with their Timeout.
+func (s *Store) write(ctx context.Context, w write, start bool) error {
+	errs := make([]error, len(s.children))
+	wg := sync.WaitGroup{}
+	for i, c := range s.children {
+		if c.Policy.Async {
+			s.enqueue(c, w, start)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, c *child) {
+			defer wg.Done()
+			if c.Policy.Required {
+				errs[i] = s.do(ctx, c, w)
+				return
+			}
+			ctx, cancel := context.WithTimeout(ctx, c.Policy.Timeout)
+			defer cancel()
+			s.do(ctx, c, w)
+		}(i, c)
+	}
+	wg.Wait()
+	for i, c := range s.children {
+		if errs[i] != nil && c.Policy.Required {
+			return xerrors.Errorf("%s: %w", c.Name, errs[i])
+		}
+	}
+	return nil
+}
+
+// Auth implements gotv.StoreV2
+func (s *Store) Auth(ctx context.Context, token string, auth string) error {
+	if len(s.children) == 0 {
+		return gotv.ErrInvalidAuth
+	}
+	return s.children[0].Store.Auth(ctx, token, auth)
+}
+
+// OnStart implements gotv.StoreV2
+func (s *Store) OnStart(ctx context.Context, token string, fragment int, f gotv.StartFrame) error {
+	return s.write(ctx, func(ctx context.Context, st gotv.StoreV2) error {
+		return st.OnStart(ctx, token, fragment, f)
+	}, true)
+}
+
+// OnFull implements gotv.StoreV2
+func (s *Store) OnFull(ctx context.Context, token string, fragment int, tick int, at time.Time, b []byte) error {
+	return s.write(ctx, func(ctx context.Context, st gotv.StoreV2) error {
+		return st.OnFull(ctx, token, fragment, tick, at, b)
+	}, false)
+}
+
+// OnDelta implements gotv.StoreV2
+func (s *Store) OnDelta(ctx context.Context, token string, fragment int, endtick int, at time.Time, final bool, b []byte) error {
+	return s.write(ctx, func(ctx context.Context, st gotv.StoreV2) error {
+		return st.OnDelta(ctx, token, fragment, endtick, at, final, b)
+	}, false)
+}
+
+// Stats returns counters of every child
+func (s *Store) Stats() []ChildStats {
+	stats := make([]ChildStats, 0, len(s.children))
+	for _, c := range s.children {
+		stats = append(stats, ChildStats{
+			Name:    c.Name,
+			Written: atomic.LoadInt64(&c.written),
+			Failed:  atomic.LoadInt64(&c.failed),
+			Dropped: atomic.LoadInt64(&c.dropped),
+			Queued:  len(c.queue),
+		})
+	}
+	return stats
+}
+
+// Close stops Async children after their queued writes. Store must not be written after Close.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		for _, c := range s.children {
+			if c.queue != nil {
+				close(c.queue)
+			}
+		}
+		s.wg.Wait()
+	})
+	return nil
+}
+
+// New Get new pointer of fan-out Store. Call Close to stop Async children.
+func New(children []Child, opts ...Option) *Store {
+	s := &Store{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, c := range children {
+		ch := &child{Child: c}
+		if ch.Policy.Timeout <= 0 {
+			ch.Policy.Timeout = 5 * time.Second
+		}
+		if c.Policy.Async {
+			size := c.Policy.Queue
+			if size <= 0 {
+				size = 64
+			}
+			ch.queue = make(chan item, size)
+			s.wg.Add(1)
+			go s.run(ch)
+		}
+		s.children = append(s.children, ch)
+	}
+	return s
+}
@@ -0,0 +1,248 @@
+package fanout_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/xerrors"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/fanout"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+// failStore fails first n writes
+type failStore struct {
+	gotv.StoreV2
+	sync.Mutex
+	n     int
+	calls int
+	err   error
+}
+
+func (f *failStore) fail() error {
+	f.Lock()
+	defer f.Unlock()
+	f.calls++
+	if f.calls <= f.n {
+		return f.err
+	}
+	return nil
+}
+
+func (f *failStore) OnStart(ctx context.Context, token string, fragment int, s gotv.StartFrame) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.StoreV2.OnStart(ctx, token, fragment, s)
+}
+
+func (f *failStore) OnFull(ctx context.Context, token string, fragment int, tick int, at time.Time, b []byte) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.StoreV2.OnFull(ctx, token, fragment, tick, at, b)
+}
+
+// blockStore blocks every full until release is closed or ctx is done
+type blockStore struct {
+	gotv.StoreV2
+	release chan struct{}
+	entered chan int // receives fragment when OnFull is called
+}
+
+func newBlockStore(s gotv.StoreV2) *blockStore {
+	return &blockStore{StoreV2: s, release: make(chan struct{}), entered: make(chan int, 16)}
+}
+
+func (b *blockStore) OnFull(ctx context.Context, token string, fragment int, tick int, at time.Time, body []byte) error {
+	b.entered <- fragment
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return b.StoreV2.OnFull(ctx, token, fragment, tick, at, body)
+}
+
+func TestPolicy(t *testing.T) {
+	errArchive := xerrors.New("archive is down")
+	for _, td := range []struct {
+		title   string
+		policy  fanout.Policy
+		fails   int
+		err     error
+		written int64
+		failed  int64
+	}{
+		{title: "required", policy: fanout.Policy{Required: true}, fails: 1, err: errArchive, failed: 1},
+		{title: "best-effort", policy: fanout.Policy{}, fails: 1, failed: 1},
+		{title: "retried", policy: fanout.Policy{Required: true, Retries: 2, Backoff: time.Millisecond}, fails: 2, written: 1},
+		{title: "retries exhausted", policy: fanout.Policy{Required: true, Retries: 2}, fails: 3, err: errArchive, failed: 1},
+	} {
+		t.Run(td.title, func(t *testing.T) {
+			asserts := assert.New(t)
+			live := inmemory.NewInmemoryGOTV("gopher")
+			defer live.Close()
+			archive := inmemory.NewInmemoryGOTV("gopher")
+			defer archive.Close()
+			errs := []string{}
+			s := fanout.New([]fanout.Child{
+				{Name: "live", Store: gotv.WrapStore(live), Policy: fanout.Policy{Required: true}},
+				{Name: "archive", Store: &failStore{StoreV2: gotv.WrapStore(archive), n: td.fails, err: errArchive}, Policy: td.policy},
+			}, fanout.WithErrorHandler(func(name string, err error) {
+				errs = append(errs, name)
+			}))
+			defer s.Close()
+
+			err := s.OnStart(context.Background(), "match", 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")})
+			if td.err != nil {
+				asserts.ErrorIs(err, td.err)
+			} else {
+				asserts.NoError(err)
+			}
+			// live path always receives the write
+			b, err := live.GetStart("match", 1)
+			asserts.NoError(err)
+			asserts.Equal([]byte("start"), b)
+
+			asserts.NoError(s.Close())
+			stats := s.Stats()
+			asserts.Equal(fanout.ChildStats{Name: "live", Written: 1}, stats[0])
+			asserts.Equal(fanout.ChildStats{Name: "archive", Written: td.written, Failed: td.failed}, stats[1])
+			if td.failed > 0 {
+				asserts.Equal([]string{"archive"}, errs)
+			}
+		})
+	}
+}
+
+func TestAuthAndMatchNotFound(t *testing.T) {
+	asserts := assert.New(t)
+	live := inmemory.NewInmemoryGOTV("gopher")
+	defer live.Close()
+	remote := &failStore{StoreV2: gotv.WrapStore(inmemory.NewInmemoryGOTV("other")), n: 10, err: gotv.ErrMatchNotFound}
+	s := fanout.New([]fanout.Child{
+		{Name: "live", Store: gotv.WrapStore(live), Policy: fanout.Policy{Required: true}},
+		{Name: "remote", Store: remote, Policy: fanout.Policy{Required: true, Retries: 3}},
+	})
+	defer s.Close()
+
+	asserts.NoError(s.Auth(context.Background(), "match", "gopher"))
+	asserts.ErrorIs(s.Auth(context.Background(), "match", "other"), gotv.ErrInvalidAuth)
+
+	// game server re-sends start on 205, so match not found is not retried
+	asserts.ErrorIs(s.OnFull(context.Background(), "match", 1, 128, time.Now(), []byte("full")), gotv.ErrMatchNotFound)
+	asserts.Equal(1, remote.calls)
+}
+
+func TestAsync(t *testing.T) {
+	asserts := assert.New(t)
+	live := inmemory.NewInmemoryGOTV("gopher")
+	defer live.Close()
+	archive := inmemory.NewInmemoryGOTV("gopher")
+	defer archive.Close()
+	slow := newBlockStore(gotv.WrapStore(archive))
+	dropped := 0
+	s := fanout.New([]fanout.Child{
+		{Name: "live", Store: gotv.WrapStore(live), Policy: fanout.Policy{Required: true}},
+		{Name: "archive", Store: slow, Policy: fanout.Policy{Async: true, Queue: 2}},
+	}, fanout.WithErrorHandler(func(name string, err error) {
+		asserts.ErrorIs(err, fanout.ErrQueueFull)
+		dropped++
+	}))
+
+	asserts.NoError(s.OnStart(context.Background(), "match", 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")}))
+	asserts.NoError(s.OnFull(context.Background(), "match", 1, 128, time.Now(), []byte("full")))
+	asserts.Equal(1, <-slow.entered)
+	// archive is stuck on fragment 1 while fragments 2 and 3 are queued, and live path is not stalled
+	for i := 2; i <= 5; i++ {
+		asserts.NoError(s.OnFull(context.Background(), "match", i, i*128, time.Now(), []byte("full")))
+	}
+	b, err := live.GetFull("match", 5)
+	asserts.NoError(err)
+	asserts.Equal([]byte("full"), b)
+	stats := s.Stats()
+	asserts.Equal(int64(2), stats[1].Dropped)
+	asserts.Equal(2, stats[1].Queued)
+
+	// queued writes are flushed on Close
+	close(slow.release)
+	asserts.NoError(s.Close())
+	asserts.Equal(fanout.ChildStats{Name: "archive", Written: 4, Dropped: 2}, s.Stats()[1])
+	asserts.Equal(2, dropped)
+	for _, fragment := range []int{1, 2, 3} {
+		_, err := archive.GetFull("match", fragment)
+		asserts.NoError(err)
+	}
+	_, err = archive.GetFull("match", 4)
+	asserts.ErrorIs(err, gotv.ErrFragmentNotFound)
+}
+
+func TestBestEffortTimeout(t *testing.T) {
+	asserts := assert.New(t)
+	live := inmemory.NewInmemoryGOTV("gopher")
+	defer live.Close()
+	archive := inmemory.NewInmemoryGOTV("gopher")
+	defer archive.Close()
+	slow := newBlockStore(gotv.WrapStore(archive))
+	errs := make(chan error, 16)
+	s := fanout.New([]fanout.Child{
+		{Name: "live", Store: gotv.WrapStore(live), Policy: fanout.Policy{Required: true}},
+		{Name: "archive", Store: slow, Policy: fanout.Policy{Timeout: 10 * time.Millisecond}},
+	}, fanout.WithErrorHandler(func(name string, err error) {
+		errs <- err
+	}))
+	defer s.Close()
+
+	// stalled best-effort child delays live path only until its timeout, and never fails it
+	asserts.NoError(s.OnStart(context.Background(), "match", 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")}))
+	for i := 1; i <= 3; i++ {
+		asserts.NoError(s.OnFull(context.Background(), "match", i, i*128, time.Now(), []byte("full")))
+		asserts.Equal(i, <-slow.entered)
+		asserts.ErrorIs(<-errs, context.DeadlineExceeded)
+	}
+	_, err := live.GetFull("match", 3)
+	asserts.NoError(err)
+	asserts.Equal(fanout.ChildStats{Name: "archive", Written: 1, Failed: 3}, s.Stats()[1])
+}
+
+func TestAsyncStartNotDropped(t *testing.T) {
+	asserts := assert.New(t)
+	live := inmemory.NewInmemoryGOTV("gopher")
+	defer live.Close()
+	archive := inmemory.NewInmemoryGOTV("gopher")
+	defer archive.Close()
+	slow := newBlockStore(gotv.WrapStore(archive))
+	s := fanout.New([]fanout.Child{
+		{Name: "live", Store: gotv.WrapStore(live), Policy: fanout.Policy{Required: true}},
+		{Name: "archive", Store: slow, Policy: fanout.Policy{Async: true, Queue: 1}},
+	})
+
+	asserts.NoError(s.OnStart(context.Background(), "match", 1, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("start")}))
+	// queue is empty again once start is written
+	asserts.Eventually(func() bool { return s.Stats()[1].Written == 1 }, time.Second, time.Millisecond)
+	asserts.NoError(s.OnFull(context.Background(), "match", 1, 128, time.Now(), []byte("full")))
+	asserts.Equal(1, <-slow.entered)
+	// archive is stuck on fragment 1, fragment 2 fills the queue and fragment 3 is dropped
+	for i := 2; i <= 3; i++ {
+		asserts.NoError(s.OnFull(context.Background(), "match", i, i*128, time.Now(), []byte("full")))
+	}
+	asserts.Equal(int64(1), s.Stats()[1].Dropped)
+
+	// new start neither waits for the queue nor is dropped
+	asserts.NoError(s.OnStart(context.Background(), "match", 4, gotv.StartFrame{At: time.Now(), Tps: 128, Body: []byte("restart")}))
+	asserts.Equal(int64(1), s.Stats()[1].Dropped)
+
+	close(slow.release)
+	asserts.NoError(s.Close())
+	asserts.Equal(fanout.ChildStats{Name: "archive", Written: 4, Dropped: 1}, s.Stats()[1])
+	b, err := archive.GetStart("match", 4)
+	asserts.NoError(err)
+	asserts.Equal([]byte("restart"), b)
+	_, err = archive.GetFull("match", 2)
+	asserts.NoError(err)
+}
@@ -0,0 +1,12 @@
+package fanout
+
+// Option Store option
+type Option func(s *Store)
+
+// WithErrorHandler calls f when a write to child fails after every retry, or is dropped because queue of the child is full.
+// f is called from ingest request or background goroutine of Async child, so it must not block.
+func WithErrorHandler(f func(name string, err error)) Option {
+	return func(s *Store) {
+		s.onError = f
+	}
+}
@@ -0,0 +1,167 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+var _ gotv.StoreV2 = (*Store)(nil)
+
+// Store gotv.StoreV2 which POSTs every fragment to remote GOTV+ ingest, same as game server with tv_broadcast_url.
+// When remote replies 205 RESET CONTENT, start fragment is sent again and the fragment is retried, as game server does.
+type Store struct {
+	sync.RWMutex
+	url       string // tv_broadcast_url of remote, e.g. "http://partner:8080/gotv"
+	auth      string // tv_broadcast_origin_auth of remote
+	localAuth string // tv_broadcast_origin_auth accepted from game server
+	c         *http.Client
+	token     func(token string) string // maps local token to remote token
+	start     map[string]*startFrame    // key=token value=last start fragment to re-send on 205
+}
+
+// startFrame start fragment and its fragment number
+type startFrame struct {
+	fragment int
+	f        gotv.StartFrame
+}
+
+// post POSTs body to /<token>/<fragment>/<field> of remote, and returns status code
+func (s *Store) post(ctx context.Context, token string, fragment int, field string, q url.Values, body []byte) (int, error) {
+	u := fmt.Sprintf("%s/%s/%d/%s", s.url, url.PathEscape(s.token(token)), fragment, field)
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("X-Origin-Auth", s.auth)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	res, err := s.c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return res.StatusCode, xerrors.Errorf("POST %s: %w", field, gotv.ErrInvalidAuth)
+	case res.StatusCode >= 300 && res.StatusCode != http.StatusResetContent:
+		return res.StatusCode, xerrors.Errorf("POST %s: unexpected status %d", field, res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// postStart POSTs start fragment
+func (s *Store) postStart(ctx context.Context, token string, fragment int, f gotv.StartFrame) error {
+	q := url.Values{}
+	q.Set("tick", strconv.Itoa(f.Tick))
+	q.Set("tps", strconv.FormatFloat(f.Tps, 'f', 1, 64))
+	q.Set("map", f.Map)
+	q.Set("protocol", strconv.Itoa(f.Protocol))
+	if f.KeyframeInterval > 0 {
+		q.Set("keyframe_interval", strconv.FormatFloat(f.KeyframeInterval, 'f', -1, 64))
+	}
+	_, err := s.post(ctx, token, fragment, "start", q, f.Body)
+	return err
+}
+
+// postFragment POSTs full or delta fragment. It re-sends start fragment and retries once if remote replies 205.
+func (s *Store) postFragment(ctx context.Context, token string, fragment int, field string, q url.Values, body []byte) error {
+	status, err := s.post(ctx, token, fragment, field, q, body)
+	if err != nil || status != http.StatusResetContent {
+		return err
+	}
+	s.RLock()
+	start, ok := s.start[token]
+	s.RUnlock()
+	if !ok {
+		// remote lost the match, and start fragment is not received yet
+		return gotv.ErrMatchNotFound
+	}
+	if err := s.postStart(ctx, token, start.fragment, start.f); err != nil {
+		return err
+	}
+	if status, err = s.post(ctx, token, fragment, field, q, body); err != nil {
+		return err
+	}
+	if status == http.StatusResetContent {
+		return gotv.ErrMatchNotFound
+	}
+	return nil
+}
+
+// Auth implements gotv.StoreV2. It accepts auth which is sent to remote unless WithAuth is given.
+func (s *Store) Auth(ctx context.Context, token string, auth string) error {
+	if auth != s.localAuth {
+		return gotv.ErrInvalidAuth
+	}
+	return nil
+}
+
+// OnStart implements gotv.StoreV2
+func (s *Store) OnStart(ctx context.Context, token string, fragment int, f gotv.StartFrame) error {
+	s.Lock()
+	s.start[token] = &startFrame{fragment: fragment, f: f}
+	s.Unlock()
+	return s.postStart(ctx, token, fragment, f)
+}
+
+// OnFull implements gotv.StoreV2
+func (s *Store) OnFull(ctx context.Context, token string, fragment int, tick int, at time.Time, b []byte) error {
+	q := url.Values{}
+	q.Set("tick", strconv.Itoa(tick))
+	return s.postFragment(ctx, token, fragment, "full", q, b)
+}
+
+// OnDelta implements gotv.StoreV2
+func (s *Store) OnDelta(ctx context.Context, token string, fragment int, endtick int, at time.Time, final bool, b []byte) error {
+	q := url.Values{}
+	q.Set("endtick", strconv.Itoa(endtick))
+	if final {
+		q.Set("final", "true")
+	}
+	if err := s.postFragment(ctx, token, fragment, "delta", q, b); err != nil {
+		return err
+	}
+	if final {
+		// match is finished, nothing is re-sent any more
+		s.Forget(token)
+	}
+	return nil
+}
+
+// Forget drops start fragment kept for token to re-send. It is dropped after final delta fragment is forwarded.
+func (s *Store) Forget(token string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.start, token)
+}
+
+// New Get new pointer of forwarding Store which POSTs to tv_broadcast_url of remote with tv_broadcast_origin_auth
+func New(broadcastURL string, auth string, opts ...Option) *Store {
+	s := &Store{
+		url:       strings.TrimSuffix(broadcastURL, "/"),
+		auth:      auth,
+		localAuth: auth,
+		c:         http.DefaultClient,
+		token:     func(token string) string { return token },
+		start:     map[string]*startFrame{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+package forward_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/forward"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+// recorder remote GOTV+ ingest which records requests
+type recorder struct {
+	sync.Mutex
+	h        http.Handler
+	requests []string // "<path>?<query>"
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	r.requests = append(r.requests, req.URL.Path+"?"+req.URL.RawQuery)
+	r.Unlock()
+	r.h.ServeHTTP(w, req)
+}
+
+func (r *recorder) reset() []string {
+	r.Lock()
+	defer r.Unlock()
+	requests := r.requests
+	r.requests = nil
+	return requests
+}
+
+func TestForward(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	remote := inmemory.NewInmemoryGOTV("partner")
+	defer remote.Close()
+	rec := &recorder{h: http.StripPrefix("/gotv", gotv.NewHTTPHandler(gotv.WrapStore(remote), gotv.WrapBroadcaster(remote)))}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	s := forward.New(srv.URL+"/gotv/", "partner", forward.WithToken(func(token string) string { return "partner-" + token }))
+	asserts.NoError(s.Auth(ctx, "match", "partner"))
+	asserts.ErrorIs(s.Auth(ctx, "match", "invalid"), gotv.ErrInvalidAuth)
+	// game servers use another password than remote
+	local := forward.New(srv.URL+"/gotv/", "partner", forward.WithAuth("gopher"))
+	asserts.NoError(local.Auth(ctx, "match", "gopher"))
+	asserts.ErrorIs(local.Auth(ctx, "match", "partner"), gotv.ErrInvalidAuth)
+
+	// remote does not know the match, and start is not received yet
+	asserts.ErrorIs(s.OnFull(ctx, "match", 1, 384, time.Now(), []byte("full")), gotv.ErrMatchNotFound)
+	rec.reset()
+
+	asserts.NoError(s.OnStart(ctx, "match", 1, gotv.StartFrame{At: time.Now(), Tick: 100, Tps: 128, KeyframeInterval: 3, Map: "de_dust2", Protocol: 4, Body: []byte("start")}))
+	asserts.NoError(s.OnFull(ctx, "match", 1, 384, time.Now(), []byte("full")))
+	asserts.NoError(s.OnDelta(ctx, "match", 1, 768, time.Now(), false, []byte("delta")))
+	asserts.Equal([]string{
+		"/gotv/partner-match/1/start?" + url.Values{"tick": {"100"}, "tps": {"128.0"}, "map": {"de_dust2"}, "protocol": {"4"}, "keyframe_interval": {"3"}}.Encode(),
+		"/gotv/partner-match/1/full?tick=384",
+		"/gotv/partner-match/1/delta?endtick=768",
+	}, rec.reset())
+
+	b, err := remote.GetStart("partner-match", 1)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start"), b)
+	synced, err := remote.GetSync("partner-match", 1)
+	asserts.NoError(err)
+	asserts.Equal("de_dust2", synced.Map)
+	asserts.Equal(4, synced.Protocol)
+
+	// remote restarted and lost the match, so it replies 205 and start is sent again
+	asserts.NoError(remote.DeleteMatch(ctx, "partner-match"))
+	asserts.NoError(s.OnFull(ctx, "match", 2, 768, time.Now(), []byte("full")))
+	asserts.Equal([]string{
+		"/gotv/partner-match/2/full?tick=768",
+		"/gotv/partner-match/1/start?" + url.Values{"tick": {"100"}, "tps": {"128.0"}, "map": {"de_dust2"}, "protocol": {"4"}, "keyframe_interval": {"3"}}.Encode(),
+		"/gotv/partner-match/2/full?tick=768",
+	}, rec.reset())
+	b, err = remote.GetFull("partner-match", 2)
+	asserts.NoError(err)
+	asserts.Equal([]byte("full"), b)
+
+	asserts.NoError(s.OnDelta(ctx, "match", 2, 1152, time.Now(), true, []byte("delta")))
+	asserts.Equal([]string{"/gotv/partner-match/2/delta?endtick=1152&final=true"}, rec.reset())
+	m, err := remote.GetFragmentMetadata(ctx, "partner-match", 2)
+	asserts.NoError(err)
+	asserts.True(m.Final)
+
+	// start is dropped after final delta fragment
+	asserts.NoError(remote.DeleteMatch(ctx, "partner-match"))
+	asserts.ErrorIs(s.OnFull(ctx, "match", 3, 1152, time.Now(), []byte("full")), gotv.ErrMatchNotFound)
+	asserts.Equal([]string{"/gotv/partner-match/3/full?tick=1152"}, rec.reset())
+
+	// remote rejects auth
+	asserts.ErrorIs(forward.New(srv.URL+"/gotv", "invalid").OnStart(ctx, "match", 1, gotv.StartFrame{Body: []byte("start")}), gotv.ErrInvalidAuth)
+}
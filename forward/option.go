@@ -0,0 +1,29 @@
+package forward
+
+import (
+	"net/http"
+)
+
+// Option Store option
+type Option func(s *Store)
+
+// WithHTTPClient POSTs with c instead of http.DefaultClient
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Store) {
+		s.c = c
+	}
+}
+
+// WithToken forwards matches to remote token f(token) instead of the same token
+func WithToken(f func(token string) string) Option {
+	return func(s *Store) {
+		s.token = f
+	}
+}
+
+// WithAuth accepts auth from game server instead of auth sent to remote, so the remote password is not shared with game servers
+func WithAuth(auth string) Option {
+	return func(s *Store) {
+		s.localAuth = auth
+	}
+}
@@ -0,0 +1,102 @@
+package gotv
+
+import (
+	"context"
+	"time"
+)
+
+var _ StoreV2 = (*storeV1Adapter)(nil)
+var _ BroadcasterV2 = (*broadcasterV1Adapter)(nil)
+
+// storeV1Adapter wraps Store into StoreV2. ctx is ignored.
+type storeV1Adapter struct {
+	s Store
+}
+
+// WrapStore wraps v1 Store into StoreV2, so existing engines can be used with context-aware handlers.
+func WrapStore(s Store) StoreV2 {
+	return &storeV1Adapter{s: s}
+}
+
+// Unwrap returns underlying v1 Store
+func (a *storeV1Adapter) Unwrap() Store {
+	return a.s
+}
+
+// Auth implements StoreV2
+func (a *storeV1Adapter) Auth(ctx context.Context, token string, auth string) error {
+	return a.s.Auth(token, auth)
+}
+
+// OnStart implements StoreV2
+func (a *storeV1Adapter) OnStart(ctx context.Context, token string, fragment int, f StartFrame) error {
+	return a.s.OnStart(token, fragment, f)
+}
+
+// OnFull implements StoreV2
+func (a *storeV1Adapter) OnFull(ctx context.Context, token string, fragment int, tick int, at time.Time, b []byte) error {
+	return a.s.OnFull(token, fragment, tick, at, b)
+}
+
+// OnDelta implements StoreV2
+func (a *storeV1Adapter) OnDelta(ctx context.Context, token string, fragment int, endtick int, at time.Time, final bool, b []byte) error {
+	return a.s.OnDelta(token, fragment, endtick, at, final, b)
+}
+
+// broadcasterV1Adapter wraps Broadcaster into BroadcasterV2. ctx is ignored.
+type broadcasterV1Adapter struct {
+	b Broadcaster
+}
+
+// WrapBroadcaster wraps v1 Broadcaster into BroadcasterV2, so existing engines can be used with context-aware handlers.
+func WrapBroadcaster(b Broadcaster) BroadcasterV2 {
+	return &broadcasterV1Adapter{b: b}
+}
+
+// Unwrap returns underlying v1 Broadcaster
+func (a *broadcasterV1Adapter) Unwrap() Broadcaster {
+	return a.b
+}
+
+// GetSync implements BroadcasterV2
+func (a *broadcasterV1Adapter) GetSync(ctx context.Context, token string, fragment int) (Sync, error) {
+	return a.b.GetSync(token, fragment)
+}
+
+// GetSyncLatest implements BroadcasterV2
+func (a *broadcasterV1Adapter) GetSyncLatest(ctx context.Context, token string) (Sync, error) {
+	return a.b.GetSyncLatest(token)
+}
+
+// GetStart implements BroadcasterV2
+func (a *broadcasterV1Adapter) GetStart(ctx context.Context, token string, fragment int) ([]byte, error) {
+	return a.b.GetStart(token, fragment)
+}
+
+// GetFull implements BroadcasterV2
+func (a *broadcasterV1Adapter) GetFull(ctx context.Context, token string, fragment int) ([]byte, error) {
+	return a.b.GetFull(token, fragment)
+}
+
+// GetDelta implements BroadcasterV2
+func (a *broadcasterV1Adapter) GetDelta(ctx context.Context, token string, fragment int) ([]byte, error) {
+	return a.b.GetDelta(token, fragment)
+}
+
+// As finds optional interface T on v, or on the engine wrapped by WrapStore/WrapBroadcaster.
+func As[T any](v any) (T, bool) {
+	for {
+		if t, ok := v.(T); ok {
+			return t, true
+		}
+		switch w := v.(type) {
+		case interface{ Unwrap() Store }:
+			v = w.Unwrap()
+		case interface{ Unwrap() Broadcaster }:
+			v = w.Unwrap()
+		default:
+			var zero T
+			return zero, false
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gotv
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// Deleter optional interface of engines which can delete stored matches and fragments.
+type Deleter interface {
+	DeleteMatch(ctx context.Context, token string) error
+	DeleteFragment(ctx context.Context, token string, fragment int) error
+}
+
+// Saver optional interface of engines which can save every match, so relay can restart without dropping live matches.
+type Saver interface {
+	Save(ctx context.Context) error
+}
+
+// AdminAuthenticator authenticates admin requests. Admin auth is separated from tv_broadcast_origin_auth.
+type AdminAuthenticator interface {
+	AdminAuth(ctx context.Context, auth string) error
+}
+
+// AdminPassword AdminAuthenticator which accepts single password
+type AdminPassword string
+
+// AdminAuth implements AdminAuthenticator
+func (p AdminPassword) AdminAuth(ctx context.Context, auth string) error {
+	if p == "" || auth != string(p) {
+		return ErrInvalidAuth
+	}
+	return nil
+}
+
+// Admin framework-agnostic core of admin requests, same as admin POSTs in hltvbroadcastrelay.js.
+//
+//	POST /:token/delete deletes a match
+//	POST /:token/:fragment_number?delete=fragment deletes a fragment
+//	POST /save saves every match
+//
+// Deleter and Saver are found on the engine separately. Requests the engine can't handle, e.g. delete on engine which is not Deleter, are 501 NOT IMPLEMENTED.
+type Admin struct {
+	d    Deleter
+	s    Saver
+	auth AdminAuthenticator
+}
+
+// NewAdmin returns new Admin of engine which implements Deleter, Saver or both.
+// engine may be wrapped by WrapStore or WrapBroadcaster, and may be nil for Admin which only authenticates.
+func NewAdmin(engine interface{}, auth AdminAuthenticator) *Admin {
+	d, _ := As[Deleter](engine)
+	s, _ := As[Saver](engine)
+	return &Admin{
+		d:    d,
+		s:    s,
+		auth: auth,
+	}
+}
+
+// Authenticate checks X-Admin-Auth. ok is false if res should be sent instead of continuing.
+func (a *Admin) Authenticate(ctx context.Context, req Request) (res Response, ok bool) {
+	if req.AdminAuth == "" {
+		return textResponse(http.StatusUnauthorized, "X-Admin-Auth required"), false
+	}
+	if a.auth == nil {
+		return textResponse(http.StatusUnauthorized, "Unauthorized"), false
+	}
+	if err := a.auth.AdminAuth(ctx, req.AdminAuth); err != nil {
+		return textResponse(http.StatusUnauthorized, "Unauthorized"), false
+	}
+	return Response{}, true
+}
+
+// Handle handles authenticated admin request
+func (a *Admin) Handle(ctx context.Context, req Request) Response {
+	if req.Method != http.MethodPost {
+		return textResponse(http.StatusMethodNotAllowed, "Admin request must be a POST request")
+	}
+	switch {
+	case req.Field == "save" && req.Token == "":
+		if a.s == nil {
+			return textResponse(http.StatusNotImplemented, "NOT IMPLEMENTED")
+		}
+		if err := a.s.Save(ctx); err != nil {
+			return internalServerError(err)
+		}
+		return textResponse(http.StatusOK, "Saved")
+	case req.Field == "delete" && req.Fragment == "":
+		if a.d == nil {
+			return textResponse(http.StatusNotImplemented, "NOT IMPLEMENTED")
+		}
+		if err := a.d.DeleteMatch(ctx, req.Token); err != nil {
+			return getErrorResponse(err)
+		}
+		return textResponse(http.StatusOK, "Match "+req.Token+" is deleted")
+	case req.Field == "" && req.Query.Get("delete") == "fragment":
+		if a.d == nil {
+			return textResponse(http.StatusNotImplemented, "NOT IMPLEMENTED")
+		}
+		fragment, err := strconv.Atoi(req.Fragment)
+		if err != nil {
+			return badRequest(err)
+		}
+		if err := a.d.DeleteFragment(ctx, req.Token, fragment); err != nil {
+			return getErrorResponse(err)
+		}
+		return textResponse(http.StatusOK, "Fragment "+req.Fragment+" is deleted")
+	}
+	return textResponse(http.StatusNotFound, "NOT FOUND")
+}
@@ -0,0 +1,434 @@
+package gotv
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Request framework-agnostic GOTV+ request. Framework adapters fill this from route params.
+type Request struct {
+	Method    string     // http.MethodGet or http.MethodPost
+	Token     string     // :token
+	Fragment  string     // :fragment_number, empty for /sync and /size
+	Field     string     // "sync", "size", "start", "full", "delta", or empty for fragment metadata
+	Query     url.Values // URL query
+	Auth      string     // X-Origin-Auth header
+	AdminAuth string     // X-Admin-Auth header
+	Body      io.Reader  // POST body
+}
+
+// Response framework-agnostic GOTV+ response. Framework adapters write this back to client.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Stream io.ReadCloser // set instead of Body when StreamBroadcaster is available. adapter must close it.
+	Size   int64         // length of Stream, -1 if unknown
+}
+
+// Core framework-agnostic GOTV+ protocol core.
+// It parses fragment numbers and queries, calls Store/Broadcaster and maps errors to status codes.
+type Core struct {
+	s StoreV2
+	b BroadcasterV2
+}
+
+// NewCore returns new Core. Either s or b can be nil if only one side is served.
+func NewCore(s StoreV2, b BroadcasterV2) *Core {
+	return &Core{
+		s: s,
+		b: b,
+	}
+}
+
+func textResponse(status int, body string) Response {
+	return Response{
+		Status: status,
+		Header: http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:   []byte(body),
+	}
+}
+
+func badRequest(err error) Response {
+	return textResponse(http.StatusBadRequest, "BadRequest:"+err.Error())
+}
+
+func internalServerError(err error) Response {
+	return textResponse(http.StatusInternalServerError, "InternalServerError:"+err.Error())
+}
+
+// Authenticate checks X-Origin-Auth for POST requests. ok is false if res should be sent instead of continuing.
+func (c *Core) Authenticate(ctx context.Context, req Request) (res Response, ok bool) {
+	if c.s == nil {
+		// Broadcaster only, e.g. edge relay
+		return textResponse(http.StatusNotFound, "NOT FOUND"), false
+	}
+	if req.Auth == "" {
+		return textResponse(http.StatusUnauthorized, "tv_broadcast_origin_auth required"), false
+	}
+	if err := c.s.Auth(ctx, req.Token, req.Auth); err != nil {
+		return textResponse(http.StatusUnauthorized, "Unauthorized"), false
+	}
+	return Response{}, true
+}
+
+// Handle handles authenticated request
+func (c *Core) Handle(ctx context.Context, req Request) Response {
+	switch {
+	case req.Method == http.MethodPost && c.s != nil:
+		switch req.Field {
+		case "start", "full", "delta":
+			return c.post(ctx, req)
+		}
+	case req.Method == http.MethodGet && c.b != nil:
+		switch req.Field {
+		case "sync":
+			return c.getSync(ctx, req)
+		case "":
+			return c.getFragmentMetadata(ctx, req)
+		case "size":
+			return c.getMatchSize(ctx, req)
+		case "start", "full", "delta":
+			return c.get(ctx, req)
+		}
+	}
+	return textResponse(http.StatusNotFound, "NOT FOUND")
+}
+
+func (c *Core) post(ctx context.Context, req Request) Response {
+	fragment, err := strconv.Atoi(req.Fragment)
+	if err != nil {
+		return badRequest(err)
+	}
+	ss, stream := As[StreamStore](c.s)
+	var b []byte
+	if !stream {
+		if b, err = io.ReadAll(req.Body); err != nil {
+			return badRequest(err)
+		}
+	}
+
+	switch req.Field {
+	case "start":
+		q, perr := parseStartQuery(req.Query)
+		if perr != nil {
+			return badRequest(perr)
+		}
+		f := StartFrame{
+			At:               time.Now(),
+			Tick:             q.Tick,
+			Tps:              q.TPS,
+			KeyframeInterval: q.KeyframeInterval,
+			Protocol:         q.Protocol,
+			Map:              q.Map,
+		}
+		if stream {
+			err = ss.OnStartStream(ctx, req.Token, fragment, f, req.Body)
+		} else {
+			f.Body = b
+			err = c.s.OnStart(ctx, req.Token, fragment, f)
+		}
+	case "full":
+		q, perr := parseFullQuery(req.Query)
+		if perr != nil {
+			return badRequest(perr)
+		}
+		if stream {
+			err = ss.OnFullStream(ctx, req.Token, fragment, q.Tick, time.Now(), req.Body)
+		} else {
+			err = c.s.OnFull(ctx, req.Token, fragment, q.Tick, time.Now(), b)
+		}
+	case "delta":
+		q, perr := parseDeltaQuery(req.Query)
+		if perr != nil {
+			return badRequest(perr)
+		}
+		if stream {
+			err = ss.OnDeltaStream(ctx, req.Token, fragment, q.EndTick, time.Now(), q.Final, req.Body)
+		} else {
+			err = c.s.OnDelta(ctx, req.Token, fragment, q.EndTick, time.Now(), q.Final, b)
+		}
+	}
+	if err != nil {
+		if xerrors.Is(err, ErrMatchNotFound) {
+			return textResponse(http.StatusResetContent, "RESET CONTENT")
+		}
+		if xerrors.Is(err, ErrFragmentNotFound) {
+			return textResponse(http.StatusNotFound, "FRAGMENT NOT FOUND")
+		}
+		return internalServerError(err)
+	}
+	return Response{Status: http.StatusOK}
+}
+
+func (c *Core) getSync(ctx context.Context, req Request) Response {
+	q, err := parseSyncQuery(req.Query)
+	if err != nil {
+		return badRequest(err)
+	}
+	var s Sync
+	if q.Fragment != 0 {
+		s, err = c.b.GetSync(ctx, req.Token, q.Fragment)
+	} else {
+		s, err = c.b.GetSyncLatest(ctx, req.Token)
+	}
+	if err != nil {
+		return getErrorResponse(err)
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return internalServerError(err)
+	}
+	return Response{
+		Status: http.StatusOK,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   b,
+	}
+}
+
+// matchDelay returns broadcast delay of the match. It is 0 if Broadcaster is not DelayBroadcaster.
+func (c *Core) matchDelay(ctx context.Context, token string) (time.Duration, error) {
+	db, ok := As[DelayBroadcaster](c.b)
+	if !ok {
+		return 0, nil
+	}
+	return db.GetMatchDelay(ctx, token)
+}
+
+// checkDelay returns ErrFragmentNotFound if fragment is received within broadcast delay d of the match, same cutoff as SelectSync
+func (c *Core) checkDelay(ctx context.Context, token string, fragment int, d time.Duration, now time.Time) error {
+	if d <= 0 {
+		return nil
+	}
+	fb, ok := As[FragmentMetadataBroadcaster](c.b)
+	if !ok {
+		return nil
+	}
+	m, err := fb.GetFragmentMetadata(ctx, token, fragment)
+	if err != nil {
+		return err
+	}
+	if time.UnixMilli(m.Timestamp).After(now.Add(-d)) {
+		return ErrFragmentNotFound
+	}
+	return nil
+}
+
+func (c *Core) getFragmentMetadata(ctx context.Context, req Request) Response {
+	fragment, err := strconv.Atoi(req.Fragment)
+	if err != nil {
+		return badRequest(err)
+	}
+	fb, ok := As[FragmentMetadataBroadcaster](c.b)
+	if !ok {
+		return textResponse(http.StatusNotImplemented, "NOT IMPLEMENTED")
+	}
+	d, err := c.matchDelay(ctx, req.Token)
+	if err != nil {
+		return getErrorResponse(err)
+	}
+	if err := c.checkDelay(ctx, req.Token, fragment, d, time.Now()); err != nil {
+		return getErrorResponse(err)
+	}
+	m, err := fb.GetFragmentMetadata(ctx, req.Token, fragment)
+	if err != nil {
+		return getErrorResponse(err)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return internalServerError(err)
+	}
+	return Response{
+		Status: http.StatusOK,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   b,
+	}
+}
+
+func (c *Core) getMatchSize(ctx context.Context, req Request) Response {
+	sb, ok := As[SizeBroadcaster](c.b)
+	if !ok {
+		return textResponse(http.StatusNotImplemented, "NOT IMPLEMENTED")
+	}
+	m, err := sb.GetMatchSize(ctx, req.Token)
+	if err != nil {
+		return getErrorResponse(err)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return internalServerError(err)
+	}
+	return Response{
+		Status: http.StatusOK,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   b,
+	}
+}
+
+func (c *Core) get(ctx context.Context, req Request) Response {
+	fragment, err := strconv.Atoi(req.Fragment)
+	if err != nil {
+		return badRequest(err)
+	}
+	d, err := c.matchDelay(ctx, req.Token)
+	if err != nil {
+		return getErrorResponse(err)
+	}
+	// start fragment is served at signup regardless of delay
+	if req.Field != "start" {
+		if err := c.checkDelay(ctx, req.Token, fragment, d, time.Now()); err != nil {
+			return getErrorResponse(err)
+		}
+	}
+	// clients could fetch fragments within the delay from URL next to the redirected one, so delayed match is served through relay
+	if rb, ok := As[RedirectBroadcaster](c.b); ok && d <= 0 {
+		u, err := rb.GetRedirectURL(ctx, req.Token, fragment, req.Field)
+		if err != nil {
+			return getErrorResponse(err)
+		}
+		if u != "" {
+			return Response{
+				Status: http.StatusFound,
+				Header: http.Header{"Location": []string{u}},
+			}
+		}
+	}
+
+	header := http.Header{"Content-Type": []string{"application/octet-stream"}}
+
+	if sb, ok := As[StreamBroadcaster](c.b); ok {
+		var r io.ReadCloser
+		var size int64
+		switch req.Field {
+		case "start":
+			r, size, err = sb.GetStartStream(ctx, req.Token, fragment)
+		case "full":
+			r, size, err = sb.GetFullStream(ctx, req.Token, fragment)
+		case "delta":
+			r, size, err = sb.GetDeltaStream(ctx, req.Token, fragment)
+		}
+		if err != nil {
+			return getErrorResponse(err)
+		}
+		return Response{
+			Status: http.StatusOK,
+			Header: header,
+			Stream: r,
+			Size:   size,
+		}
+	}
+
+	var b []byte
+	switch req.Field {
+	case "start":
+		b, err = c.b.GetStart(ctx, req.Token, fragment)
+	case "full":
+		b, err = c.b.GetFull(ctx, req.Token, fragment)
+	case "delta":
+		b, err = c.b.GetDelta(ctx, req.Token, fragment)
+	}
+	if err != nil {
+		return getErrorResponse(err)
+	}
+	return Response{
+		Status: http.StatusOK,
+		Header: header,
+		Body:   b,
+	}
+}
+
+// getErrorResponse maps Broadcaster error to response
+func getErrorResponse(err error) Response {
+	if xerrors.Is(err, ErrStartExpired) {
+		return textResponse(http.StatusNotFound, "Invalid or expired start fragment, please re-sync")
+	}
+	if xerrors.Is(err, ErrMatchNotFound) {
+		return textResponse(http.StatusNotFound, "MATCH NOT FOUND")
+	}
+	if xerrors.Is(err, ErrFragmentNotFound) {
+		return textResponse(http.StatusNotFound, "FRAGMENT NOT FOUND")
+	}
+	return internalServerError(err)
+}
+
+// queryInt parses optional int query parameter
+func queryInt(q url.Values, key string) (int, error) {
+	v := q.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// queryFloat parses optional float query parameter
+func queryFloat(q url.Values, key string) (float64, error) {
+	v := q.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// queryBool parses optional bool query parameter
+func queryBool(q url.Values, key string) (bool, error) {
+	v := q.Get(key)
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+func parseStartQuery(q url.Values) (StartQuery, error) {
+	var err error
+	ret := StartQuery{Map: q.Get("map")}
+	if ret.Tick, err = queryInt(q, "tick"); err != nil {
+		return ret, err
+	}
+	if ret.TPS, err = queryFloat(q, "tps"); err != nil {
+		return ret, err
+	}
+	if ret.KeyframeInterval, err = queryFloat(q, "keyframe_interval"); err != nil {
+		return ret, err
+	}
+	if ret.Protocol, err = queryInt(q, "protocol"); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
+
+func parseFullQuery(q url.Values) (FullQuery, error) {
+	var err error
+	ret := FullQuery{}
+	if ret.Tick, err = queryInt(q, "tick"); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
+
+func parseDeltaQuery(q url.Values) (DeltaQuery, error) {
+	var err error
+	ret := DeltaQuery{}
+	if ret.EndTick, err = queryInt(q, "endtick"); err != nil {
+		return ret, err
+	}
+	if ret.Final, err = queryBool(q, "final"); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
+
+func parseSyncQuery(q url.Values) (SyncQuery, error) {
+	var err error
+	ret := SyncQuery{}
+	if ret.Fragment, err = queryInt(q, "fragment"); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
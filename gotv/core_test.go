@@ -0,0 +1,398 @@
+package gotv_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/disk"
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+// serveFunc sends request to framework and returns response
+type serveFunc func(req *http.Request) (*http.Response, error)
+
+func newNetHTTP(t *testing.T, snapshot string) serveFunc {
+	m := inmemory.NewInmemoryGOTV("gopher", inmemory.WithSnapshotFile(snapshot))
+	t.Cleanup(func() { m.Close() })
+	mux := http.NewServeMux()
+	mux.Handle("/gotv/", http.StripPrefix("/gotv", gotv.NewHTTPHandler(gotv.WrapStore(m), gotv.WrapBroadcaster(m))))
+	mux.Handle("/admin/", http.StripPrefix("/admin", gotv.NewAdminHTTPHandler(m, gotv.AdminPassword("admin"))))
+	return func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Result(), nil
+	}
+}
+
+func newFiber(t *testing.T, snapshot string) serveFunc {
+	m := inmemory.NewInmemoryGOTV("gopher", inmemory.WithSnapshotFile(snapshot))
+	t.Cleanup(func() { m.Close() })
+	app := fiber.New()
+	g := app.Group("/gotv")
+	gotv.SetupStoreHandlersFiber(gotv.WrapStore(m), g)
+	gotv.SetupBroadcasterHandlersFiber(gotv.WrapBroadcaster(m), g)
+	gotv.SetupAdminHandlersFiber(m, gotv.AdminPassword("admin"), app.Group("/admin"))
+	return func(req *http.Request) (*http.Response, error) {
+		return app.Test(req)
+	}
+}
+
+func newGin(t *testing.T, snapshot string) serveFunc {
+	gin.SetMode(gin.TestMode)
+	m := inmemory.NewInmemoryGOTV("gopher", inmemory.WithSnapshotFile(snapshot))
+	t.Cleanup(func() { m.Close() })
+	app := gin.New()
+	g := app.Group("/gotv")
+	gotv.SetupStoreHandlersGin(gotv.WrapStore(m), g)
+	gotv.SetupBroadcasterHandlersGin(gotv.WrapBroadcaster(m), g)
+	gotv.SetupAdminHandlersGin(m, gotv.AdminPassword("admin"), app.Group("/admin"))
+	return func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec.Result(), nil
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	dir := t.TempDir()
+	for name, serve := range map[string]serveFunc{
+		"net/http": newNetHTTP(t, filepath.Join(dir, "nethttp.snapshot")),
+		"fiber":    newFiber(t, filepath.Join(dir, "fiber.snapshot")),
+		"gin":      newGin(t, filepath.Join(dir, "gin.snapshot")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			testHandlers(t, serve)
+		})
+	}
+}
+
+func testHandlers(t *testing.T, serve serveFunc) {
+	for _, td := range []struct {
+		title  string
+		method string
+		path   string
+		auth   string
+		admin  string
+		body   string
+		status int
+	}{
+		{title: "auth required", method: http.MethodPost, path: "/gotv/match/1/start", status: http.StatusUnauthorized},
+		{title: "wrong auth", method: http.MethodPost, path: "/gotv/match/1/start", auth: "wrong", status: http.StatusUnauthorized},
+		{title: "full before start", method: http.MethodPost, path: "/gotv/match/1/full?tick=1", auth: "gopher", status: http.StatusResetContent},
+		{title: "start", method: http.MethodPost, path: "/gotv/match/1/start?tick=1&tps=128.0&map=de_dust2&protocol=4", auth: "gopher", body: "start", status: http.StatusOK},
+		{title: "full", method: http.MethodPost, path: "/gotv/match/1/full?tick=1", auth: "gopher", body: "full", status: http.StatusOK},
+		{title: "delta", method: http.MethodPost, path: "/gotv/match/1/delta?endtick=128&final=false", auth: "gopher", body: "delta", status: http.StatusOK},
+		{title: "bad fragment", method: http.MethodGet, path: "/gotv/match/abc/full", status: http.StatusBadRequest},
+		{title: "bad query", method: http.MethodPost, path: "/gotv/match/2/full?tick=abc", auth: "gopher", status: http.StatusBadRequest},
+		{title: "bad keyframe interval", method: http.MethodPost, path: "/gotv/match/2/start?tps=128&keyframe_interval=abc", auth: "gopher", status: http.StatusBadRequest},
+		{title: "get start", method: http.MethodGet, path: "/gotv/match/1/start", body: "start", status: http.StatusOK},
+		{title: "expired start", method: http.MethodGet, path: "/gotv/match/2/start", status: http.StatusNotFound},
+		{title: "get full", method: http.MethodGet, path: "/gotv/match/1/full", body: "full", status: http.StatusOK},
+		{title: "get delta", method: http.MethodGet, path: "/gotv/match/1/delta", body: "delta", status: http.StatusOK},
+		{title: "fragment metadata", method: http.MethodGet, path: "/gotv/match/1", status: http.StatusOK},
+		{title: "unknown fragment metadata", method: http.MethodGet, path: "/gotv/match/3", status: http.StatusNotFound},
+		{title: "match size", method: http.MethodGet, path: "/gotv/match/size", body: `{"bytes":14,"fragments":1}`, status: http.StatusOK},
+		{title: "unknown match", method: http.MethodGet, path: "/gotv/unknown/sync", status: http.StatusNotFound},
+		{title: "unknown route", method: http.MethodGet, path: "/gotv/match/1/unknown", status: http.StatusNotFound},
+		{title: "save without admin auth", method: http.MethodPost, path: "/admin/save", status: http.StatusUnauthorized},
+		{title: "save", method: http.MethodPost, path: "/admin/save", admin: "admin", status: http.StatusOK},
+		{title: "delete without admin auth", method: http.MethodPost, path: "/admin/match/delete", status: http.StatusUnauthorized},
+		{title: "delete with origin auth", method: http.MethodPost, path: "/admin/match/delete", admin: "gopher", status: http.StatusUnauthorized},
+		{title: "delete fragment", method: http.MethodPost, path: "/admin/match/1?delete=fragment", admin: "admin", status: http.StatusOK},
+		{title: "deleted fragment", method: http.MethodGet, path: "/gotv/match/1/full", status: http.StatusNotFound},
+		{title: "delete match", method: http.MethodPost, path: "/admin/match/delete", admin: "admin", status: http.StatusOK},
+		{title: "deleted match", method: http.MethodGet, path: "/gotv/match/sync", status: http.StatusNotFound},
+		{title: "delete unknown match", method: http.MethodPost, path: "/admin/match/delete", admin: "admin", status: http.StatusNotFound},
+	} {
+		t.Run(td.title, func(t *testing.T) {
+			asserts := assert.New(t)
+			req := httptest.NewRequest(td.method, td.path, strings.NewReader(td.body))
+			if td.auth != "" {
+				req.Header.Set("X-Origin-Auth", td.auth)
+			}
+			if td.admin != "" {
+				req.Header.Set("X-Admin-Auth", td.admin)
+			}
+			res, err := serve(req)
+			if !asserts.NoError(err) {
+				return
+			}
+			defer res.Body.Close()
+			asserts.Equal(td.status, res.StatusCode)
+			if td.method == http.MethodGet && td.status == http.StatusOK && td.body != "" {
+				b, _ := io.ReadAll(res.Body)
+				asserts.Equal(td.body, string(b))
+			}
+		})
+	}
+}
+
+func TestHandlerWithoutStore(t *testing.T) {
+	asserts := assert.New(t)
+	m := inmemory.NewInmemoryGOTV("gopher")
+	defer m.Close()
+	h := gotv.NewHTTPHandler(nil, gotv.WrapBroadcaster(m))
+
+	req := httptest.NewRequest(http.MethodPost, "/match/1/start", strings.NewReader("start"))
+	req.Header.Set("X-Origin-Auth", "gopher")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	asserts.Equal(http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/match/sync", nil))
+	asserts.Equal(http.StatusNotFound, rec.Code)
+}
+
+func TestAdminWithoutDeleter(t *testing.T) {
+	asserts := assert.New(t)
+	h := gotv.NewAdminHTTPHandler(nil, gotv.AdminPassword("admin"))
+	for _, path := range []string{"/match/delete", "/match/1?delete=fragment", "/save"} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("X-Admin-Auth", "admin")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		asserts.Equal(http.StatusNotImplemented, rec.Code, path)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/save", nil)
+	req.Header.Set("X-Admin-Auth", "admin")
+	gotv.NewAdminHTTPHandler(nil, nil).ServeHTTP(rec, req)
+	asserts.Equal(http.StatusUnauthorized, rec.Code)
+}
+
+// saverFunc engine which can save but can't delete
+type saverFunc func(ctx context.Context) error
+
+func (f saverFunc) Save(ctx context.Context) error { return f(ctx) }
+
+func TestAdminSaverOnly(t *testing.T) {
+	asserts := assert.New(t)
+	saved := 0
+	h := gotv.NewAdminHTTPHandler(saverFunc(func(ctx context.Context) error {
+		saved++
+		return nil
+	}), gotv.AdminPassword("admin"))
+	for _, td := range []struct {
+		path     string
+		expected int
+	}{
+		{path: "/save", expected: http.StatusOK},
+		{path: "/match/delete", expected: http.StatusNotImplemented},
+		{path: "/match/1?delete=fragment", expected: http.StatusNotImplemented},
+	} {
+		req := httptest.NewRequest(http.MethodPost, td.path, nil)
+		req.Header.Set("X-Admin-Auth", "admin")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		asserts.Equal(td.expected, rec.Code, td.path)
+	}
+	asserts.Equal(1, saved)
+}
+
+func TestCoreStream(t *testing.T) {
+	d, err := disk.NewDiskGOTV("gopher", t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	m := inmemory.NewInmemoryGOTV("gopher")
+	defer m.Close()
+	for _, td := range []struct {
+		title    string
+		s        gotv.Store
+		b        gotv.Broadcaster
+		streamed bool
+	}{
+		{title: "buffered", s: m, b: m},
+		{title: "streamed", s: d, b: d, streamed: true},
+	} {
+		t.Run(td.title, func(t *testing.T) {
+			asserts := assert.New(t)
+			ctx := context.Background()
+			c := gotv.NewCore(gotv.WrapStore(td.s), gotv.WrapBroadcaster(td.b))
+			for _, post := range []struct {
+				field string
+				query url.Values
+			}{
+				{field: "start", query: url.Values{"tick": {"1"}, "tps": {"128.0"}, "map": {"de_dust2"}, "protocol": {"4"}}},
+				{field: "full", query: url.Values{"tick": {"1"}}},
+				{field: "delta", query: url.Values{"endtick": {"128"}}},
+			} {
+				res := c.Handle(ctx, gotv.Request{Method: http.MethodPost, Token: "match", Fragment: "1", Field: post.field, Query: post.query, Body: strings.NewReader(post.field)})
+				asserts.Equal(http.StatusOK, res.Status)
+			}
+			for _, field := range []string{"start", "full", "delta"} {
+				res := c.Handle(ctx, gotv.Request{Method: http.MethodGet, Token: "match", Fragment: "1", Field: field})
+				asserts.Equal(http.StatusOK, res.Status)
+				asserts.Equal("application/octet-stream", res.Header.Get("Content-Type"))
+				if !td.streamed {
+					asserts.Nil(res.Stream)
+					asserts.Equal(field, string(res.Body))
+					continue
+				}
+				if asserts.NotNil(res.Stream) {
+					b, err := io.ReadAll(res.Stream)
+					asserts.NoError(err)
+					asserts.NoError(res.Stream.Close())
+					asserts.Equal(field, string(b))
+					asserts.Equal(int64(len(field)), res.Size)
+				}
+			}
+			res := c.Handle(ctx, gotv.Request{Method: http.MethodGet, Token: "match", Fragment: "2", Field: "full"})
+			asserts.Equal(http.StatusNotFound, res.Status)
+			asserts.Nil(res.Stream)
+		})
+	}
+}
+
+func TestCoreDelay(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	m := inmemory.NewInmemoryGOTV("gopher", inmemory.WithDelay(10*time.Second))
+	defer m.Close()
+	now := time.Now()
+	asserts.NoError(m.OnStart("match", 1, gotv.StartFrame{At: now.Add(-time.Minute), Tps: 128, Protocol: 4, Map: "de_dust2", Body: []byte("start")}))
+	asserts.NoError(m.OnFull("match", 1, 1, now.Add(-time.Minute), []byte("full1")))
+	asserts.NoError(m.OnDelta("match", 1, 128, now.Add(-time.Minute), false, []byte("delta1")))
+	asserts.NoError(m.OnFull("match", 2, 129, now, []byte("full2")))
+	asserts.NoError(m.OnDelta("match", 2, 256, now, false, []byte("delta2")))
+
+	c := gotv.NewCore(gotv.WrapStore(m), gotv.WrapBroadcaster(m))
+	for _, td := range []struct {
+		title    string
+		fragment string
+		field    string
+		status   int
+	}{
+		{title: "start", fragment: "1", field: "start", status: http.StatusOK},
+		{title: "delayed full", fragment: "1", field: "full", status: http.StatusOK},
+		{title: "delayed delta", fragment: "1", field: "delta", status: http.StatusOK},
+		{title: "full within delay", fragment: "2", field: "full", status: http.StatusNotFound},
+		{title: "delta within delay", fragment: "2", field: "delta", status: http.StatusNotFound},
+		{title: "metadata within delay", fragment: "2", status: http.StatusNotFound},
+	} {
+		res := c.Handle(ctx, gotv.Request{Method: http.MethodGet, Token: "match", Fragment: td.fragment, Field: td.field})
+		asserts.Equal(td.status, res.Status, td.title)
+	}
+
+	// per match override
+	asserts.NoError(m.SetMatchDelay(ctx, "match", 0))
+	res := c.Handle(ctx, gotv.Request{Method: http.MethodGet, Token: "match", Fragment: "2", Field: "full"})
+	asserts.Equal(http.StatusOK, res.Status)
+	asserts.Equal("full2", string(res.Body))
+}
+
+type ctxKey struct{}
+
+// ctxBroadcaster records ctx passed by the HTTP adapter
+type ctxBroadcaster struct {
+	gotv.BroadcasterV2
+	ctx context.Context
+}
+
+func (b *ctxBroadcaster) GetSync(ctx context.Context, token string, fragment int) (gotv.Sync, error) {
+	b.ctx = ctx
+	return b.BroadcasterV2.GetSync(ctx, token, fragment)
+}
+
+func (b *ctxBroadcaster) GetSyncLatest(ctx context.Context, token string) (gotv.Sync, error) {
+	b.ctx = ctx
+	return b.BroadcasterV2.GetSyncLatest(ctx, token)
+}
+
+func TestHandlerContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := inmemory.NewInmemoryGOTV("gopher")
+	defer m.Close()
+	b := &ctxBroadcaster{BroadcasterV2: gotv.WrapBroadcaster(m)}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "request"))
+	cancel()
+
+	h := gotv.NewHTTPHandler(nil, b)
+	g := gin.New()
+	gotv.SetupBroadcasterHandlersGin(b, g.Group("/"))
+	f := fiber.New()
+	// fasthttp does not cancel on disconnect, so context is given by middleware
+	f.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(ctx)
+		return c.Next()
+	})
+	gotv.SetupBroadcasterHandlersFiber(b, f)
+	for name, serve := range map[string]serveFunc{
+		"net/http": func(req *http.Request) (*http.Response, error) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec.Result(), nil
+		},
+		"gin": func(req *http.Request) (*http.Response, error) {
+			rec := httptest.NewRecorder()
+			g.ServeHTTP(rec, req)
+			return rec.Result(), nil
+		},
+		"fiber": func(req *http.Request) (*http.Response, error) {
+			return f.Test(req)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			asserts := assert.New(t)
+			b.ctx = nil
+			res, err := serve(httptest.NewRequest(http.MethodGet, "/match/sync", nil).WithContext(ctx))
+			if !asserts.NoError(err) {
+				return
+			}
+			res.Body.Close()
+			asserts.Equal(http.StatusNotFound, res.StatusCode)
+			if asserts.NotNil(b.ctx) {
+				asserts.Equal("request", b.ctx.Value(ctxKey{}))
+				asserts.ErrorIs(b.ctx.Err(), context.Canceled)
+			}
+		})
+	}
+}
+
+// brokenBroadcaster serves fragment stream which fails after the first bytes
+type brokenBroadcaster struct {
+	gotv.BroadcasterV2
+}
+
+func (b *brokenBroadcaster) GetStartStream(ctx context.Context, token string, fragment int) (io.ReadCloser, int64, error) {
+	return b.GetFullStream(ctx, token, fragment)
+}
+
+func (b *brokenBroadcaster) GetFullStream(ctx context.Context, token string, fragment int) (io.ReadCloser, int64, error) {
+	return io.NopCloser(io.MultiReader(strings.NewReader("ful"), iotest.ErrReader(io.ErrUnexpectedEOF))), -1, nil
+}
+
+func (b *brokenBroadcaster) GetDeltaStream(ctx context.Context, token string, fragment int) (io.ReadCloser, int64, error) {
+	return b.GetFullStream(ctx, token, fragment)
+}
+
+func TestHTTPHandlerBrokenStream(t *testing.T) {
+	asserts := assert.New(t)
+	m := inmemory.NewInmemoryGOTV("gopher")
+	defer m.Close()
+	srv := httptest.NewServer(gotv.NewHTTPHandler(nil, &brokenBroadcaster{BroadcasterV2: gotv.WrapBroadcaster(m)}))
+	defer srv.Close()
+
+	// truncated fragment is not served as complete one. Connection is aborted before or after the header is flushed.
+	res, err := http.Get(srv.URL + "/match/1/full")
+	if err == nil {
+		defer res.Body.Close()
+		_, err = io.ReadAll(res.Body)
+	}
+	asserts.Error(err)
+}
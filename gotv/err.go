@@ -6,4 +6,6 @@ var (
 	ErrInvalidAuth      = xerrors.New("Invalid Authentication")
 	ErrFragmentNotFound = xerrors.New("Fragment Not Found")
 	ErrMatchNotFound    = xerrors.New("Match Not Found")
+	ErrStartExpired     = xerrors.New("Invalid or expired start fragment, please re-sync")
+	ErrMissingFragment  = xerrors.New("Missing Fragment") // a fragment before the final or the latest fragment is not stored while reading Recording
 )
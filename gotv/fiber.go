@@ -1,216 +1,173 @@
 package gotv
 
 import (
-	"strconv"
-	"time"
+	"bytes"
+	"io"
+	"net/url"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
-	"golang.org/x/xerrors"
 )
 
-// CheckAuthMiddlewareFiber Check Auth on Fiber
-func CheckAuthMiddlewareFiber(g Store) func(c *fiber.Ctx) error {
-	return (func(c *fiber.Ctx) error {
-		hs := c.GetReqHeaders()
-		auth, ok := hs["X-Origin-Auth"]
-		if !ok {
-			return c.Status(fiber.StatusUnauthorized).SendString("tv_broadcast_origin_auth required")
-		}
-		token := utils.CopyString(c.Params("token"))
-		if err := g.Auth(token, auth); err != nil {
-			return c.Status(fiber.StatusUnauthorized).SendString("Unauthorized")
-		}
-		return c.Next()
-	})
+// requestFiber builds Request from fiber.Ctx
+func requestFiber(c *fiber.Ctx, field string) Request {
+	q, _ := url.ParseQuery(string(c.Context().QueryArgs().QueryString()))
+	return Request{
+		Method:    c.Method(),
+		Token:     utils.CopyString(c.Params("token")),
+		Fragment:  utils.CopyString(c.Params("fragment_number")),
+		Field:     field,
+		Query:     q,
+		Auth:      c.Get("X-Origin-Auth"),
+		AdminAuth: c.Get("X-Admin-Auth"),
+		Body:      bodyReaderFiber(c),
+	}
 }
 
-// OnStartFragmentHandlerFiber Register start fragment on Fiber
-func OnStartFragmentHandlerFiber(g Store) func(c *fiber.Ctx) error {
-	return (func(c *fiber.Ctx) error {
-		token := utils.CopyString(c.Params("token"))
-		fragment, err := strconv.Atoi(c.Params("fragment_number"))
-		if err != nil {
-			return err
-		}
-		q := StartQuery{}
-		if err := c.QueryParser(&q); err != nil {
-			return c.Status(fiber.StatusBadRequest).SendString("BadRequest:" + err.Error())
-		}
-		if err := g.OnStart(token, fragment, StartFrame{
-			At:       time.Now(),
-			Tps:      q.TPS,
-			Protocol: q.Protocol,
-			Map:      q.Map,
-			Body:     utils.CopyBytes(c.Body()),
-		}); err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				return c.Status(fiber.StatusResetContent).SendString("RESET CONTENT")
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("FRAGMENT NOT FOUND")
-			}
-			return err
-		}
-		return nil
-	})
+// bodyReaderFiber returns request body as io.Reader. Body is streamed if fiber.Config.StreamRequestBody is enabled.
+func bodyReaderFiber(c *fiber.Ctx) io.Reader {
+	if c.Request().IsBodyStream() {
+		return c.Context().RequestBodyStream()
+	}
+	return bytes.NewReader(c.Body())
+}
+
+// writeResponseFiber writes Response to fiber.Ctx
+func writeResponseFiber(c *fiber.Ctx, res Response) error {
+	for k, v := range res.Header {
+		for _, vv := range v {
+			c.Set(k, vv)
+		}
+	}
+	c.Status(res.Status)
+	if res.Stream != nil {
+		// fasthttp closes Stream after the response is sent
+		return c.SendStream(res.Stream, int(res.Size))
+	}
+	return c.Send(res.Body)
 }
 
-// OnFullFragmentHandlerFiber Register start fragment on Fiber
-func OnFullFragmentHandlerFiber(g Store) func(c *fiber.Ctx) error {
+// handlerFiber returns fiber handler which passes request with field to Core.
+// fasthttp does not notify client disconnect, so ctx is only cancelled if middleware sets cancellable user context e.g. with timeout.
+func handlerFiber(core *Core, field string) func(c *fiber.Ctx) error {
 	return (func(c *fiber.Ctx) error {
-		token := utils.CopyString(c.Params("token"))
-		fragment, err := strconv.Atoi(c.Params("fragment_number"))
-		if err != nil {
-			return err
-		}
-		q := FullQuery{}
-		if err := c.QueryParser(&q); err != nil {
-			return c.Status(fiber.StatusBadRequest).SendString("BadRequest:" + err.Error())
-		}
-		if err := g.OnFull(token, fragment, q.Tick, time.Now(), utils.CopyBytes(c.Body())); err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				return c.Status(fiber.StatusResetContent).SendString("RESET CONTENT")
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("FRAGMENT NOT FOUND")
-			}
-			return err
-		}
-		return nil
+		return writeResponseFiber(c, core.Handle(c.UserContext(), requestFiber(c, field)))
 	})
 }
 
-// OnDeltaFragmentHandlerFiber Register start fragment on Fiber
-func OnDeltaFragmentHandlerFiber(g Store) func(c *fiber.Ctx) error {
+// CheckAuthMiddlewareFiber Check Auth on Fiber
+func CheckAuthMiddlewareFiber(g StoreV2) func(c *fiber.Ctx) error {
+	core := NewCore(g, nil)
 	return (func(c *fiber.Ctx) error {
-		token := utils.CopyString(c.Params("token"))
-		fragment, err := strconv.Atoi(c.Params("fragment_number"))
-		if err != nil {
-			return err
+		if res, ok := core.Authenticate(c.UserContext(), requestFiber(c, "")); !ok {
+			return writeResponseFiber(c, res)
 		}
-		q := DeltaQuery{}
-		if err := c.QueryParser(&q); err != nil {
-			return c.Status(fiber.StatusBadRequest).SendString("BadRequest:" + err.Error())
-		}
-		if err := g.OnDelta(token, fragment, q.EndTick, time.Now(), q.Final, utils.CopyBytes(c.Body())); err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				return c.Status(fiber.StatusResetContent).SendString("RESET CONTENT")
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("FRAGMENT NOT FOUND")
-			}
-			return err
-		}
-		return nil
+		return c.Next()
 	})
 }
 
-// GetSyncRequestHandlerFiber Register start fragment on Fiber
-func GetSyncRequestHandlerFiber(b Broadcaster) func(c *fiber.Ctx) error {
+// CheckAdminAuthMiddlewareFiber Check admin Auth on Fiber
+func CheckAdminAuthMiddlewareFiber(auth AdminAuthenticator) func(c *fiber.Ctx) error {
+	admin := NewAdmin(nil, auth)
 	return (func(c *fiber.Ctx) error {
-		token := utils.CopyString(c.Params("token"))
-		q := SyncQuery{}
-		if err := c.QueryParser(&q); err != nil {
-			return c.Status(fiber.StatusBadRequest).SendString("BadRequest:" + err.Error())
+		if res, ok := admin.Authenticate(c.UserContext(), requestFiber(c, "")); !ok {
+			return writeResponseFiber(c, res)
 		}
-		var s Sync
-		var err error
-		if q.Fragment != 0 {
-			s, err = b.GetSync(token, q.Fragment)
-		} else {
-			s, err = b.GetSyncLatest(token)
-		}
-		if err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("MATCH NOT FOUND")
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("FRAGMENT NOT FOUND")
-			}
-			return err
-		}
-		return c.JSON(s)
+		return c.Next()
 	})
 }
 
+// OnStartFragmentHandlerFiber Register start fragment on Fiber
+func OnStartFragmentHandlerFiber(g StoreV2) func(c *fiber.Ctx) error {
+	return handlerFiber(NewCore(g, nil), "start")
+}
+
+// OnFullFragmentHandlerFiber Register full fragment on Fiber
+func OnFullFragmentHandlerFiber(g StoreV2) func(c *fiber.Ctx) error {
+	return handlerFiber(NewCore(g, nil), "full")
+}
+
+// OnDeltaFragmentHandlerFiber Register delta fragment on Fiber
+func OnDeltaFragmentHandlerFiber(g StoreV2) func(c *fiber.Ctx) error {
+	return handlerFiber(NewCore(g, nil), "delta")
+}
+
+// GetSyncRequestHandlerFiber Get sync JSON on Fiber
+func GetSyncRequestHandlerFiber(b BroadcasterV2) func(c *fiber.Ctx) error {
+	return handlerFiber(NewCore(nil, b), "sync")
+}
+
+// GetFragmentMetadataRequestHandlerFiber Get fragment metadata JSON on Fiber
+func GetFragmentMetadataRequestHandlerFiber(b BroadcasterV2) func(c *fiber.Ctx) error {
+	return handlerFiber(NewCore(nil, b), "")
+}
+
+// GetMatchSizeRequestHandlerFiber Get match buffer size JSON on Fiber
+func GetMatchSizeRequestHandlerFiber(b BroadcasterV2) func(c *fiber.Ctx) error {
+	return handlerFiber(NewCore(nil, b), "size")
+}
+
 // GetStartRequestHandlerFiber Get start fragment on Fiber
-func GetStartRequestHandlerFiber(b Broadcaster) func(c *fiber.Ctx) error {
+func GetStartRequestHandlerFiber(b BroadcasterV2) func(c *fiber.Ctx) error {
+	return handlerFiber(NewCore(nil, b), "start")
+}
+
+// GetFullRequestHandlerFiber Get full fragment on Fiber
+func GetFullRequestHandlerFiber(b BroadcasterV2) func(c *fiber.Ctx) error {
+	return handlerFiber(NewCore(nil, b), "full")
+}
+
+// GetDeltaRequestHandlerFiber Get delta fragment on Fiber
+func GetDeltaRequestHandlerFiber(b BroadcasterV2) func(c *fiber.Ctx) error {
+	return handlerFiber(NewCore(nil, b), "delta")
+}
+
+// DeleteMatchHandlerFiber Delete match on Fiber
+func DeleteMatchHandlerFiber(d Deleter) func(c *fiber.Ctx) error {
+	admin := NewAdmin(d, nil)
 	return (func(c *fiber.Ctx) error {
-		token := utils.CopyString(c.Params("token"))
-		fragment, err := strconv.Atoi(c.Params("fragment_number"))
-		if err != nil {
-			return err
-		}
-		s, err := b.GetStart(token, fragment)
-		if err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("MATCH NOT FOUND")
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("FRAGMENT NOT FOUND")
-			}
-			return err
-		}
-		return c.Status(fiber.StatusOK).Send(s)
+		return writeResponseFiber(c, admin.Handle(c.UserContext(), requestFiber(c, "delete")))
 	})
 }
 
-// GetFullRequestHandlerFiber Get start fragment on Fiber
-func GetFullRequestHandlerFiber(b Broadcaster) func(c *fiber.Ctx) error {
+// DeleteFragmentHandlerFiber Delete fragment on Fiber
+func DeleteFragmentHandlerFiber(d Deleter) func(c *fiber.Ctx) error {
+	admin := NewAdmin(d, nil)
 	return (func(c *fiber.Ctx) error {
-		token := utils.CopyString(c.Params("token"))
-		fragment, err := strconv.Atoi(c.Params("fragment_number"))
-		if err != nil {
-			return err
-		}
-		s, err := b.GetFull(token, fragment)
-		if err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("MATCH NOT FOUND")
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("FRAGMENT NOT FOUND")
-			}
-			return err
-		}
-		return c.Status(fiber.StatusOK).Send(s)
+		return writeResponseFiber(c, admin.Handle(c.UserContext(), requestFiber(c, "")))
 	})
 }
 
-// GetDeltaRequestHandlerFiber Get delta fragment on Fiber
-func GetDeltaRequestHandlerFiber(b Broadcaster) func(c *fiber.Ctx) error {
+// SaveHandlerFiber Save every match on Fiber
+func SaveHandlerFiber(s Saver) func(c *fiber.Ctx) error {
+	admin := NewAdmin(s, nil)
 	return (func(c *fiber.Ctx) error {
-		token := utils.CopyString(c.Params("token"))
-		fragment, err := strconv.Atoi(c.Params("fragment_number"))
-		if err != nil {
-			return err
-		}
-		d, err := b.GetDelta(token, fragment)
-		if err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("MATCH NOT FOUND")
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				return c.Status(fiber.StatusNotFound).SendString("FRAGMENT NOT FOUND")
-			}
-			return err
-		}
-		return c.Status(fiber.StatusOK).Send(d)
+		return writeResponseFiber(c, admin.Handle(c.UserContext(), requestFiber(c, "save")))
 	})
 }
 
 // SetupStoreHandlers setup Store handlers to specified fiber.Router
-func SetupStoreHandlersFiber(g Store, r fiber.Router) {
+func SetupStoreHandlersFiber(g StoreV2, r fiber.Router) {
 	r.Post("/:token/:fragment_number/start", CheckAuthMiddlewareFiber(g), OnStartFragmentHandlerFiber(g))
 	r.Post("/:token/:fragment_number/full", CheckAuthMiddlewareFiber(g), OnFullFragmentHandlerFiber(g))
 	r.Post("/:token/:fragment_number/delta", CheckAuthMiddlewareFiber(g), OnDeltaFragmentHandlerFiber(g))
 }
 
 // SetupBroadcasterHandlers setup Broadcaster handlers to specified fiber.Router
-func SetupBroadcasterHandlersFiber(b Broadcaster, r fiber.Router) {
+func SetupBroadcasterHandlersFiber(b BroadcasterV2, r fiber.Router) {
 	r.Get("/:token/sync", GetSyncRequestHandlerFiber(b))
+	r.Get("/:token/size", GetMatchSizeRequestHandlerFiber(b))
 	r.Get("/:token/:fragment_number/start", GetStartRequestHandlerFiber(b))
 	r.Get("/:token/:fragment_number/full", GetFullRequestHandlerFiber(b))
 	r.Get("/:token/:fragment_number/delta", GetDeltaRequestHandlerFiber(b))
+	r.Get("/:token/:fragment_number", GetFragmentMetadataRequestHandlerFiber(b))
+}
+
+// SetupAdminHandlersFiber setup admin handlers of engine which implements Deleter, Saver or both to specified fiber.Router
+func SetupAdminHandlersFiber(engine interface{}, auth AdminAuthenticator, r fiber.Router) {
+	d, _ := As[Deleter](engine)
+	s, _ := As[Saver](engine)
+	r.Post("/save", CheckAdminAuthMiddlewareFiber(auth), SaveHandlerFiber(s))
+	r.Post("/:token/delete", CheckAdminAuthMiddlewareFiber(auth), DeleteMatchHandlerFiber(d))
+	r.Post("/:token/:fragment_number", CheckAdminAuthMiddlewareFiber(auth), DeleteFragmentHandlerFiber(d))
 }
@@ -1,28 +1,64 @@
 package gotv
 
 import (
-	"io"
-	"net/http"
-	"strconv"
-	"time"
-
 	"github.com/gin-gonic/gin"
-	"golang.org/x/xerrors"
 )
 
+// requestGin builds Request from gin.Context
+func requestGin(c *gin.Context, field string) Request {
+	return Request{
+		Method:    c.Request.Method,
+		Token:     c.Param("token"),
+		Fragment:  c.Param("fragment_number"),
+		Field:     field,
+		Query:     c.Request.URL.Query(),
+		Auth:      c.Request.Header.Get("X-Origin-Auth"),
+		AdminAuth: c.Request.Header.Get("X-Admin-Auth"),
+		Body:      c.Request.Body,
+	}
+}
+
+// writeResponseGin writes Response to gin.Context
+func writeResponseGin(c *gin.Context, res Response) {
+	for k, v := range res.Header {
+		c.Writer.Header()[k] = v
+	}
+	if res.Stream != nil {
+		defer res.Stream.Close()
+		c.DataFromReader(res.Status, res.Size, res.Header.Get("Content-Type"), res.Stream, nil)
+		return
+	}
+	c.Data(res.Status, res.Header.Get("Content-Type"), res.Body)
+	if res.Status >= 300 {
+		c.Abort()
+	}
+}
+
+// handlerGin returns gin handler which passes request with field to Core
+func handlerGin(core *Core, field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeResponseGin(c, core.Handle(c.Request.Context(), requestGin(c, field)))
+	}
+}
+
 // CheckAuthMiddlewareGin Check Auth on Gin
-func CheckAuthMiddlewareGin(g Store) gin.HandlerFunc {
+func CheckAuthMiddlewareGin(g StoreV2) gin.HandlerFunc {
+	core := NewCore(g, nil)
 	return func(c *gin.Context) {
-		token := c.Param("token")
-		auth := c.Request.Header.Get("X-Origin-Auth")
-		if auth == "" {
-			c.String(http.StatusUnauthorized, "tv_broadcast_origin_auth required")
-			c.Abort()
+		if res, ok := core.Authenticate(c.Request.Context(), requestGin(c, "")); !ok {
+			writeResponseGin(c, res)
 			return
 		}
-		if err := g.Auth(token, auth); err != nil {
-			c.String(http.StatusUnauthorized, "Unauthorized")
-			c.Abort()
+		c.Next()
+	}
+}
+
+// CheckAdminAuthMiddlewareGin Check admin Auth on Gin
+func CheckAdminAuthMiddlewareGin(auth AdminAuthenticator) gin.HandlerFunc {
+	admin := NewAdmin(nil, auth)
+	return func(c *gin.Context) {
+		if res, ok := admin.Authenticate(c.Request.Context(), requestGin(c, "")); !ok {
+			writeResponseGin(c, res)
 			return
 		}
 		c.Next()
@@ -30,261 +66,96 @@ func CheckAuthMiddlewareGin(g Store) gin.HandlerFunc {
 }
 
 // OnStartFragmentHandlerGin Register start fragment on Gin
-func OnStartFragmentHandlerGin(g Store) func(c *gin.Context) {
-	return (func(c *gin.Context) {
-		token := c.Param("token")
-		fragment, err := strconv.Atoi(c.Param("fragment_number"))
-		if err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		q := StartQuery{}
-		if err := c.BindQuery(&q); err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		b, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		if err := g.OnStart(token, fragment, StartFrame{
-			At:       time.Now(),
-			Tps:      q.TPS,
-			Protocol: q.Protocol,
-			Map:      q.Map,
-			Body:     b,
-		}); err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				c.String(http.StatusResetContent, "RESET CONTENT")
-				c.Abort()
-				return
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				c.String(http.StatusNotFound, "FRAGMENT NOT FOUND")
-				c.Abort()
-				return
-			}
-			return
-		}
-		return
-	})
+func OnStartFragmentHandlerGin(g StoreV2) func(c *gin.Context) {
+	return handlerGin(NewCore(g, nil), "start")
 }
 
-// OnFullFragmentHandlerGin Register start fragment on Gin
-func OnFullFragmentHandlerGin(g Store) func(c *gin.Context) {
-	return (func(c *gin.Context) {
-		token := c.Param("token")
-		fragment, err := strconv.Atoi(c.Param("fragment_number"))
-		if err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		q := FullQuery{}
-		if err := c.BindQuery(&q); err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		b, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		if err := g.OnFull(token, fragment, q.Tick, time.Now(), b); err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				c.String(http.StatusResetContent, "RESET CONTENT")
-				c.Abort()
-				return
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				c.String(http.StatusNotFound, "FRAGMENT NOT FOUND")
-				c.Abort()
-				return
-			}
-			return
-		}
-		return
-	})
+// OnFullFragmentHandlerGin Register full fragment on Gin
+func OnFullFragmentHandlerGin(g StoreV2) func(c *gin.Context) {
+	return handlerGin(NewCore(g, nil), "full")
 }
 
-// OnDeltaFragmentHandlerGin Register start fragment on Gin
-func OnDeltaFragmentHandlerGin(g Store) func(c *gin.Context) {
-	return (func(c *gin.Context) {
-		token := c.Param("token")
-		fragment, err := strconv.Atoi(c.Param("fragment_number"))
-		if err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		q := DeltaQuery{}
-		if err := c.BindQuery(&q); err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		b, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		if err := g.OnDelta(token, fragment, q.EndTick, time.Now(), q.Final, b); err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				c.String(http.StatusResetContent, "RESET CONTENT")
-				c.Abort()
-				return
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				c.String(http.StatusNotFound, "FRAGMENT NOT FOUND")
-				c.Abort()
-				return
-			}
-			return
-		}
-		return
-	})
+// OnDeltaFragmentHandlerGin Register delta fragment on Gin
+func OnDeltaFragmentHandlerGin(g StoreV2) func(c *gin.Context) {
+	return handlerGin(NewCore(g, nil), "delta")
 }
 
 // GetSyncRequestHandlerGin get sync JSON on Gin
-func GetSyncRequestHandlerGin(b Broadcaster) func(c *gin.Context) {
-	return func(c *gin.Context) {
-		token := c.Param("token")
-		q := SyncQuery{}
-		if err := c.BindQuery(&q); err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		var s Sync
-		var err error
-		if q.Fragment != 0 {
-			s, err = b.GetSync(token, q.Fragment)
-		} else {
-			s, err = b.GetSyncLatest(token)
-		}
-		if err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				c.String(http.StatusNotFound, "MATCH NOT FOUND")
-				c.Abort()
-				return
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				c.String(http.StatusNotFound, "FRAGMENT NOT FOUND")
-				c.Abort()
-				return
-			}
-			return
-		}
-		c.JSON(http.StatusOK, s)
-		return
-	}
+func GetSyncRequestHandlerGin(b BroadcasterV2) func(c *gin.Context) {
+	return handlerGin(NewCore(nil, b), "sync")
+}
+
+// GetFragmentMetadataRequestHandlerGin get fragment metadata JSON on Gin
+func GetFragmentMetadataRequestHandlerGin(b BroadcasterV2) func(c *gin.Context) {
+	return handlerGin(NewCore(nil, b), "")
+}
+
+// GetMatchSizeRequestHandlerGin get match buffer size JSON on Gin
+func GetMatchSizeRequestHandlerGin(b BroadcasterV2) func(c *gin.Context) {
+	return handlerGin(NewCore(nil, b), "size")
 }
 
 // GetStartRequestHandlerGin get start on Gin
-func GetStartRequestHandlerGin(b Broadcaster) func(c *gin.Context) {
+func GetStartRequestHandlerGin(b BroadcasterV2) func(c *gin.Context) {
+	return handlerGin(NewCore(nil, b), "start")
+}
+
+// GetFullRequestHandlerGin get full on Gin
+func GetFullRequestHandlerGin(b BroadcasterV2) func(c *gin.Context) {
+	return handlerGin(NewCore(nil, b), "full")
+}
+
+// GetDeltaRequestHandlerGin get delta on Gin
+func GetDeltaRequestHandlerGin(b BroadcasterV2) func(c *gin.Context) {
+	return handlerGin(NewCore(nil, b), "delta")
+}
+
+// DeleteMatchHandlerGin delete match on Gin
+func DeleteMatchHandlerGin(d Deleter) func(c *gin.Context) {
+	admin := NewAdmin(d, nil)
 	return func(c *gin.Context) {
-		token := c.Param("token")
-		fragment, err := strconv.Atoi(c.Param("fragment_number"))
-		if err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		b, err := b.GetStart(token, fragment)
-		if err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				c.String(http.StatusNotFound, "MATCH NOT FOUND")
-				c.Abort()
-				return
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				c.String(http.StatusNotFound, "FRAGMENT NOT FOUND")
-				c.Abort()
-				return
-			}
-			return
-		}
-		c.Data(http.StatusOK, "application/octet-stream", b)
-		return
+		writeResponseGin(c, admin.Handle(c.Request.Context(), requestGin(c, "delete")))
 	}
 }
 
-// GetFullRequestHandlerGin get full on Gin
-func GetFullRequestHandlerGin(b Broadcaster) func(c *gin.Context) {
+// DeleteFragmentHandlerGin delete fragment on Gin
+func DeleteFragmentHandlerGin(d Deleter) func(c *gin.Context) {
+	admin := NewAdmin(d, nil)
 	return func(c *gin.Context) {
-		token := c.Param("token")
-		fragment, err := strconv.Atoi(c.Param("fragment_number"))
-		if err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		b, err := b.GetFull(token, fragment)
-		if err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				c.String(http.StatusNotFound, "MATCH NOT FOUND")
-				c.Abort()
-				return
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				c.String(http.StatusNotFound, "FRAGMENT NOT FOUND")
-				c.Abort()
-				return
-			}
-			return
-		}
-		c.Data(http.StatusOK, "application/octet-stream", b)
-		return
+		writeResponseGin(c, admin.Handle(c.Request.Context(), requestGin(c, "")))
 	}
 }
 
-// GetDeltaRequestHandlerGin get full on Gin
-func GetDeltaRequestHandlerGin(b Broadcaster) func(c *gin.Context) {
+// SaveHandlerGin save every match on Gin
+func SaveHandlerGin(s Saver) func(c *gin.Context) {
+	admin := NewAdmin(s, nil)
 	return func(c *gin.Context) {
-		token := c.Param("token")
-		fragment, err := strconv.Atoi(c.Param("fragment_number"))
-		if err != nil {
-			c.String(http.StatusBadRequest, "BadRequest:"+err.Error())
-			c.Abort()
-			return
-		}
-		b, err := b.GetDelta(token, fragment)
-		if err != nil {
-			if xerrors.Is(err, ErrMatchNotFound) {
-				c.String(http.StatusNotFound, "MATCH NOT FOUND")
-				c.Abort()
-				return
-			}
-			if xerrors.Is(err, ErrFragmentNotFound) {
-				c.String(http.StatusNotFound, "FRAGMENT NOT FOUND")
-				c.Abort()
-				return
-			}
-			return
-		}
-		c.Data(http.StatusOK, "application/octet-stream", b)
-		return
+		writeResponseGin(c, admin.Handle(c.Request.Context(), requestGin(c, "save")))
 	}
 }
 
 // SetupStoreHandlersGin setup Store handlers to gin.RouterGroup
-func SetupStoreHandlersGin(g Store, r *gin.RouterGroup) {
+func SetupStoreHandlersGin(g StoreV2, r *gin.RouterGroup) {
 	r.POST("/:token/:fragment_number/start", CheckAuthMiddlewareGin(g), OnStartFragmentHandlerGin(g))
 	r.POST("/:token/:fragment_number/full", CheckAuthMiddlewareGin(g), OnFullFragmentHandlerGin(g))
 	r.POST("/:token/:fragment_number/delta", CheckAuthMiddlewareGin(g), OnDeltaFragmentHandlerGin(g))
 }
 
 // SetupBroadcasterHandlersGin setup Broadcaster handlers to specified gin.RouterGroup
-func SetupBroadcasterHandlersGin(b Broadcaster, r *gin.RouterGroup) {
+func SetupBroadcasterHandlersGin(b BroadcasterV2, r *gin.RouterGroup) {
 	r.GET("/:token/sync", GetSyncRequestHandlerGin(b))
+	r.GET("/:token/size", GetMatchSizeRequestHandlerGin(b))
 	r.GET("/:token/:fragment_number/start", GetStartRequestHandlerGin(b))
 	r.GET("/:token/:fragment_number/full", GetFullRequestHandlerGin(b))
 	r.GET("/:token/:fragment_number/delta", GetDeltaRequestHandlerGin(b))
+	r.GET("/:token/:fragment_number", GetFragmentMetadataRequestHandlerGin(b))
+}
+
+// SetupAdminHandlersGin setup admin handlers of engine which implements Deleter, Saver or both to specified gin.RouterGroup
+func SetupAdminHandlersGin(engine interface{}, auth AdminAuthenticator, r *gin.RouterGroup) {
+	d, _ := As[Deleter](engine)
+	s, _ := As[Saver](engine)
+	r.POST("/save", CheckAdminAuthMiddlewareGin(auth), SaveHandlerGin(s))
+	r.POST("/:token/delete", CheckAdminAuthMiddlewareGin(auth), DeleteMatchHandlerGin(d))
+	r.POST("/:token/:fragment_number", CheckAdminAuthMiddlewareGin(auth), DeleteFragmentHandlerGin(d))
 }
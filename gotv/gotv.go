@@ -1,6 +1,8 @@
 package gotv
 
 import (
+	"context"
+	"io"
 	"time"
 )
 
@@ -16,11 +18,80 @@ type Store interface {
 type Broadcaster interface {
 	GetSync(token string, fragment int) (Sync, error)
 	GetSyncLatest(token string) (Sync, error)
-	GetStart(token string, fragment int) ([]byte, error) // could be io.Reader??
+	GetStart(token string, fragment int) ([]byte, error) // ErrStartExpired unless fragment is the current signup fragment. see StreamBroadcaster for io.Reader version
 	GetFull(token string, fragment int) ([]byte, error)
 	GetDelta(token string, fragment int) ([]byte, error)
 }
 
+// StoreV2 context-aware version of Store. WRITE ONLY OPERATION.
+// ctx is the request context of the HTTP adapter. net/http and gin cancel it when the ingesting server disconnects,
+// Fiber passes fiber.Ctx.UserContext which fasthttp does not cancel on disconnect.
+type StoreV2 interface {
+	Auth(ctx context.Context, token string, auth string) error
+	OnStart(ctx context.Context, token string, fragment int, f StartFrame) error
+	OnFull(ctx context.Context, token string, fragment int, tick int, at time.Time, b []byte) error
+	OnDelta(ctx context.Context, token string, fragment int, endtick int, at time.Time, final bool, b []byte) error
+}
+
+// BroadcasterV2 context-aware version of Broadcaster. READ ONLY OPERATION.
+// ctx is the request context of the HTTP adapter, cancelled on disconnect of the playcast client same as StoreV2.
+type BroadcasterV2 interface {
+	GetSync(ctx context.Context, token string, fragment int) (Sync, error)
+	GetSyncLatest(ctx context.Context, token string) (Sync, error)
+	GetStart(ctx context.Context, token string, fragment int) ([]byte, error) // ErrStartExpired unless fragment is the current signup fragment
+	GetFull(ctx context.Context, token string, fragment int) ([]byte, error)
+	GetDelta(ctx context.Context, token string, fragment int) ([]byte, error)
+}
+
+// StreamStore optional extension of StoreV2 which receives fragment bodies as io.Reader instead of []byte.
+// r is only valid until the method returns. StartFrame.Body is nil, read the body from r instead.
+type StreamStore interface {
+	OnStartStream(ctx context.Context, token string, fragment int, f StartFrame, r io.Reader) error
+	OnFullStream(ctx context.Context, token string, fragment int, tick int, at time.Time, r io.Reader) error
+	OnDeltaStream(ctx context.Context, token string, fragment int, endtick int, at time.Time, final bool, r io.Reader) error
+}
+
+// StreamBroadcaster optional extension of BroadcasterV2 which serves fragment bodies as io.ReadCloser instead of []byte.
+// size is the length of the body, or -1 if unknown. Caller must close returned reader.
+type StreamBroadcaster interface {
+	GetStartStream(ctx context.Context, token string, fragment int) (r io.ReadCloser, size int64, err error) // ErrStartExpired unless fragment is the current signup fragment
+	GetFullStream(ctx context.Context, token string, fragment int) (r io.ReadCloser, size int64, err error)
+	GetDeltaStream(ctx context.Context, token string, fragment int) (r io.ReadCloser, size int64, err error)
+}
+
+// FragmentMetadataBroadcaster optional extension of BroadcasterV2 which serves fragment metadata at GET /:token/:fragment_number.
+type FragmentMetadataBroadcaster interface {
+	GetFragmentMetadata(ctx context.Context, token string, fragment int) (FragmentMetadata, error)
+}
+
+// SizeBroadcaster optional extension of BroadcasterV2 which serves per-match buffer usage at GET /:token/size.
+type SizeBroadcaster interface {
+	GetMatchSize(ctx context.Context, token string) (MatchSize, error)
+}
+
+// RedirectBroadcaster optional extension of BroadcasterV2 which lets clients download start, full and delta fragments
+// straight from another URL such as object storage or CDN. Core responds 302 Found to the URL.
+// field is "start", "full" or "delta". Return empty url to serve the fragment as usual.
+// Core never redirects requests for a match with broadcast delay from DelayBroadcaster, because clients could fetch
+// fragments within the delay straight from the URL. Such fragments are served as usual.
+type RedirectBroadcaster interface {
+	GetRedirectURL(ctx context.Context, token string, fragment int, field string) (url string, err error)
+}
+
+// DelayBroadcaster optional extension of BroadcasterV2 which has broadcast delay per match, like tv_delay.
+// Core does not serve fragments received within the delay, so clients can't skip it by requesting fragments newer than /sync.
+// Fragment metadata is required to know when the fragment was received, see FragmentMetadataBroadcaster.
+type DelayBroadcaster interface {
+	GetMatchDelay(ctx context.Context, token string) (time.Duration, error)
+	SetMatchDelay(ctx context.Context, token string, d time.Duration) error // overrides default delay of the match at runtime
+}
+
+// MatchInfoBroadcaster optional extension of BroadcasterV2 which serves match-wide state regardless of broadcast delay,
+// for tools which read the whole stored match e.g. exporting and replaying it.
+type MatchInfoBroadcaster interface {
+	GetMatchInfo(ctx context.Context, token string) (MatchInfo, error) // ErrMatchNotFound if the match does not exist
+}
+
 // Fragment has both of Full/Delta fragment data
 type Fragment struct {
 	At      time.Time
@@ -33,11 +104,13 @@ type Fragment struct {
 
 // StartFrame Start fragment
 type StartFrame struct {
-	At       time.Time
-	Tps      float64 // Even though it is int, we should use float64 because server sends its value as "128.0"
-	Protocol int
-	Map      string
-	Body     []byte
+	At               time.Time
+	Tick             int     // the starting tick of the broadcast
+	Tps              float64 // Even though it is int, we should use float64 because server sends its value as "128.0"
+	KeyframeInterval float64 // 0 if game server did not send it
+	Protocol         int
+	Map              string
+	Body             []byte
 }
 
 // Sync /sync JSON
@@ -55,13 +128,29 @@ type Sync struct {
 	Protocol         int     `json:"protocol"`
 }
 
+// FragmentMetadata fragment metadata JSON, same as getFragmentMetadata in hltvbroadcastrelay.js
+type FragmentMetadata struct {
+	Tick      int   `json:"tick"`
+	EndTick   int   `json:"endtick"`
+	Final     bool  `json:"final,omitempty"`
+	Timestamp int64 `json:"timestamp"` // unix time in milliseconds relay received the fragment
+	Full      int   `json:"full"`      // full fragment size in bytes
+	Delta     int   `json:"delta"`     // delta fragment size in bytes
+}
+
+// MatchSize buffer usage of a match, like /<token>/size in hltvbroadcastrelay.js
+type MatchSize struct {
+	Bytes     int64 `json:"bytes"`     // total bytes of start, full and delta fragments
+	Fragments int   `json:"fragments"` // number of fragments stored
+}
+
 // StartQuery Query for START request
 type StartQuery struct {
-	Tick             int     `query:"tick" form:"tick"` // the starting tick of the broadcast
-	TPS              float64 `query:"tps" form:"tps"`   // the tickrate of the GOTV broadcast. // 実際はintだが128.0 という小数点付きで送られてくるのでfloatに設定する
-	Map              string  `query:"map" form:"map"`   // the name of the map
-	KeyframeInterval float64 `json:"keyframe_interval,omitempty"`
-	Protocol         int     `query:"protocol" form:"protocol"` // Currently 4
+	Tick             int     `query:"tick" form:"tick"`                           // the starting tick of the broadcast
+	TPS              float64 `query:"tps" form:"tps"`                             // the tickrate of the GOTV broadcast. // 実際はintだが128.0 という小数点付きで送られてくるのでfloatに設定する
+	Map              string  `query:"map" form:"map"`                             // the name of the map
+	KeyframeInterval float64 `query:"keyframe_interval" form:"keyframe_interval"` // seconds between full fragments. optional
+	Protocol         int     `query:"protocol" form:"protocol"`                   // Currently 4
 }
 
 // FullQuery Query for FULL request
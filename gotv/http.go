@@ -0,0 +1,119 @@
+package gotv
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// httpHandler net/http GOTV+ handler
+type httpHandler struct {
+	c *Core
+}
+
+// NewHTTPHandler returns net/http handler which serves same routes as SetupStoreHandlersFiber and SetupBroadcasterHandlersFiber.
+// Either s or b can be nil to disable its routes.
+// Routes are parsed from r.URL.Path as is, so the handler only works at the root of the server or behind http.StripPrefix,
+// e.g. mux.Handle("/gotv/", http.StripPrefix("/gotv", h)). Without StripPrefix, "gotv" would be parsed as token.
+func NewHTTPHandler(s StoreV2, b BroadcasterV2) http.Handler {
+	return &httpHandler{
+		c: NewCore(s, b),
+	}
+}
+
+// requestHTTP builds Request from http.Request. ok is false if path does not match any route.
+// r.URL.Path must be relative to the mount point of the handler, see NewHTTPHandler.
+func requestHTTP(r *http.Request) (req Request, ok bool) {
+	req = Request{
+		Method:    r.Method,
+		Query:     r.URL.Query(),
+		Auth:      r.Header.Get("X-Origin-Auth"),
+		AdminAuth: r.Header.Get("X-Admin-Auth"),
+		Body:      r.Body,
+	}
+	// /save, /:token/sync, /:token/size, /:token/delete, /:token/:fragment_number or /:token/:fragment_number/:field
+	p := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(p) == 1 && p[0] == "save":
+		req.Field = p[0]
+	case len(p) == 2 && (p[1] == "sync" || p[1] == "size" || p[1] == "delete"):
+		req.Token, req.Field = p[0], p[1]
+	case len(p) == 2:
+		req.Token, req.Fragment = p[0], p[1]
+	case len(p) == 3:
+		req.Token, req.Fragment, req.Field = p[0], p[1], p[2]
+	default:
+		return req, false
+	}
+	return req, true
+}
+
+// ServeHTTP implements http.Handler
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, ok := requestHTTP(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if req.Method == http.MethodPost {
+		if res, ok := h.c.Authenticate(r.Context(), req); !ok {
+			writeResponseHTTP(w, res)
+			return
+		}
+	}
+	writeResponseHTTP(w, h.c.Handle(r.Context(), req))
+}
+
+// adminHTTPHandler net/http GOTV+ admin handler
+type adminHTTPHandler struct {
+	a *Admin
+}
+
+// NewAdminHTTPHandler returns net/http handler which serves same routes as SetupAdminHandlersFiber.
+// engine implements Deleter, Saver or both, see NewAdmin.
+// Mount it on a separate prefix from NewHTTPHandler, e.g. mux.Handle("/admin/", http.StripPrefix("/admin", h)).
+func NewAdminHTTPHandler(engine interface{}, auth AdminAuthenticator) http.Handler {
+	return &adminHTTPHandler{
+		a: NewAdmin(engine, auth),
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *adminHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, ok := requestHTTP(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if res, ok := h.a.Authenticate(r.Context(), req); !ok {
+		writeResponseHTTP(w, res)
+		return
+	}
+	writeResponseHTTP(w, h.a.Handle(r.Context(), req))
+}
+
+// writeResponseHTTP writes Response to http.ResponseWriter.
+// Status is already sent when writing body fails, so the connection is aborted instead,
+// otherwise client may take truncated fragment without Content-Length as complete one.
+func writeResponseHTTP(w http.ResponseWriter, res Response) {
+	for k, v := range res.Header {
+		w.Header()[k] = v
+	}
+	var err error
+	if res.Stream != nil {
+		defer res.Stream.Close()
+		if res.Size >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(res.Size, 10))
+		}
+		w.WriteHeader(res.Status)
+		_, err = io.Copy(w, res.Stream)
+	} else {
+		w.WriteHeader(res.Status)
+		_, err = w.Write(res.Body)
+	}
+	if err != nil {
+		// http.Server closes the connection without logging
+		panic(http.ErrAbortHandler)
+	}
+}
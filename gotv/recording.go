@@ -0,0 +1,60 @@
+package gotv
+
+import (
+	"context"
+)
+
+// Recording stored match to read from its beginning, e.g. to export or replay it
+type Recording struct {
+	MatchInfo
+	First     int // first fragment from the signup fragment which has full fragment
+	FirstTick int // tick of First
+}
+
+// GetFragmentMetadataOf returns metadata of fragment if b serves FragmentMetadataBroadcaster and has the fragment
+func GetFragmentMetadataOf(ctx context.Context, b BroadcasterV2, token string, fragment int) (FragmentMetadata, bool) {
+	fb, ok := As[FragmentMetadataBroadcaster](b)
+	if !ok {
+		return FragmentMetadata{}, false
+	}
+	m, err := fb.GetFragmentMetadata(ctx, token, fragment)
+	if err != nil || m.EndTick == 0 {
+		return FragmentMetadata{}, false
+	}
+	return m, true
+}
+
+// ReadRecording reads match info of token and finds its first fragment.
+// Broadcast delay is ignored if b serves MatchInfoBroadcaster. Otherwise /sync is used, and Latest is 0 as it is unknown.
+// Returns ErrFragmentNotFound if no fragment has full fragment.
+func ReadRecording(ctx context.Context, b BroadcasterV2, token string) (Recording, error) {
+	mb, ok := As[MatchInfoBroadcaster](b)
+	if !ok {
+		s, err := b.GetSync(ctx, token, 1)
+		if err != nil {
+			return Recording{}, err
+		}
+		return Recording{
+			MatchInfo: MatchInfo{
+				SignupFragment:   s.SignupFragment,
+				TickPerSecond:    float64(s.TickPerSecond),
+				KeyframeInterval: s.KeyframeInterval,
+				Map:              s.Map,
+				Protocol:         s.Protocol,
+			},
+			First:     s.Fragment,
+			FirstTick: s.Tick,
+		}, nil
+	}
+	info, err := mb.GetMatchInfo(ctx, token)
+	if err != nil {
+		return Recording{}, err
+	}
+	for fragment := info.SignupFragment; fragment <= info.Latest; fragment++ {
+		m, ok := GetFragmentMetadataOf(ctx, b, token, fragment)
+		if ok && m.Full > 0 {
+			return Recording{MatchInfo: info, First: fragment, FirstTick: m.Tick}, nil
+		}
+	}
+	return Recording{}, ErrFragmentNotFound
+}
@@ -0,0 +1,139 @@
+package gotv
+
+import (
+	"time"
+)
+
+// SyncLatestOffset /sync without ?fragment= serves the fragment this many fragments before the latest one.
+// hltvbroadcastrelay.js uses acc.length - 8, where acc.length is latest fragment + 1.
+// Skipping the last few fragments lets front-running clients get 404 and CDN wait and retry,
+// and keeps a few fragments of buffer ahead of what the client is streaming.
+const SyncLatestOffset = 7
+
+// DefaultProtocol protocol reported in /sync if game server did not send one
+const DefaultProtocol = 4
+
+// MatchInfo match-wide state reported in /sync
+type MatchInfo struct {
+	SignupFragment   int       // fragment number of the current start frame
+	Latest           int       // latest fragment number received from game server
+	ReceivedAt       time.Time // last time relay received data from game server
+	TickPerSecond    float64
+	KeyframeInterval float64
+	Map              string
+	Protocol         int
+	Delay            time.Duration // broadcast delay like tv_delay
+}
+
+// FragmentInfo per-fragment state used to select /sync fragment
+type FragmentInfo struct {
+	At       time.Time // time relay received the fragment
+	Tick     int
+	EndTick  int
+	Final    bool
+	HasFull  bool
+	HasDelta bool
+}
+
+// IsSyncReady reports whether clients can start playing from the fragment. Both full and delta must be received.
+func (f FragmentInfo) IsSyncReady() bool {
+	return f.HasFull && f.HasDelta
+}
+
+// SyncSource match state which SelectSync reads. Engines implement this for each match.
+type SyncSource interface {
+	MatchInfo() MatchInfo
+	FragmentInfo(fragment int) (FragmentInfo, bool)
+}
+
+// SelectSyncFragment selects fragment to start playing from at now, same as respondAccSync in hltvbroadcastrelay.js.
+// If fragment is 0 (not requested), the fragment SyncLatestOffset before the latest one is used
+// if it is sync ready and not before the signup fragment.
+// Otherwise fragment is clamped to the signup fragment and walked forward to the first sync ready fragment.
+//
+// If MatchInfo.Delay is set, fragments received after now - Delay are never selected.
+// Latest sync walks back to the newest fragment old enough, and requested fragment newer than that is clamped to it.
+func SelectSyncFragment(src SyncSource, fragment int, now time.Time) (int, FragmentInfo, error) {
+	m := src.MatchInfo()
+	cutoff := now.Add(-m.Delay)
+	if fragment == 0 {
+		fragment = m.Latest - SyncLatestOffset
+		return selectSyncBackward(src, m, fragment, cutoff)
+	}
+
+	if fragment < m.SignupFragment {
+		fragment = m.SignupFragment
+	}
+	for ; fragment <= m.Latest; fragment++ {
+		f, ok := src.FragmentInfo(fragment)
+		if !ok {
+			continue
+		}
+		if f.At.After(cutoff) {
+			// requested fragment is newer than broadcast delay allows
+			return selectSyncBackward(src, m, fragment-1, cutoff)
+		}
+		if f.IsSyncReady() {
+			return fragment, f, nil
+		}
+	}
+	return 0, FragmentInfo{}, ErrFragmentNotFound
+}
+
+// selectSyncBackward selects fragment at or before fragment which was received before cutoff.
+// Without delay only fragment itself is checked, same as hltvbroadcastrelay.js.
+func selectSyncBackward(src SyncSource, m MatchInfo, fragment int, cutoff time.Time) (int, FragmentInfo, error) {
+	// can't serve anything before the start fragment
+	for ; fragment >= 0 && fragment >= m.SignupFragment; fragment-- {
+		f, ok := src.FragmentInfo(fragment)
+		if ok && !f.At.After(cutoff) && f.IsSyncReady() {
+			return fragment, f, nil
+		}
+		if m.Delay <= 0 {
+			break
+		}
+	}
+	return 0, FragmentInfo{}, ErrFragmentNotFound
+}
+
+// EstimateKeyframeInterval derives keyframe interval in seconds from two consecutive full fragments,
+// for game servers which do not send keyframe_interval in start request.
+// Tick spacing is used if tps is known, otherwise time the relay received them. Returns 0 if neither is available.
+func EstimateKeyframeInterval(prev, cur FragmentInfo, tps float64) float64 {
+	if tps > 0 && cur.Tick > prev.Tick {
+		return float64(cur.Tick-prev.Tick) / tps
+	}
+	if !prev.At.IsZero() && cur.At.After(prev.At) {
+		return cur.At.Sub(prev.At).Seconds()
+	}
+	return 0
+}
+
+// SelectSync selects fragment with SelectSyncFragment and builds Sync response at now.
+func SelectSync(src SyncSource, fragment int, now time.Time) (Sync, error) {
+	fragment, f, err := SelectSyncFragment(src, fragment, now)
+	if err != nil {
+		return Sync{}, err
+	}
+	return NewSync(src.MatchInfo(), fragment, f, now), nil
+}
+
+// NewSync builds Sync response of fragment at now.
+func NewSync(m MatchInfo, fragment int, f FragmentInfo, now time.Time) Sync {
+	protocol := m.Protocol
+	if protocol == 0 {
+		protocol = DefaultProtocol
+	}
+	return Sync{
+		Tick:             f.Tick,
+		Endtick:          f.EndTick,
+		RealTimeDelay:    now.Sub(f.At).Seconds(),
+		ReceiveAge:       now.Sub(m.ReceivedAt).Seconds(),
+		Fragment:         fragment,
+		SignupFragment:   m.SignupFragment,
+		TickPerSecond:    int(m.TickPerSecond),
+		KeyframeInterval: m.KeyframeInterval,
+		Map:              m.Map,
+		Protocol:         protocol,
+	}
+}
@@ -0,0 +1,149 @@
+package gotv_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+// testSyncSource gotv.SyncSource for tests
+type testSyncSource struct {
+	match     gotv.MatchInfo
+	fragments map[int]gotv.FragmentInfo
+}
+
+func (s *testSyncSource) MatchInfo() gotv.MatchInfo {
+	return s.match
+}
+
+func (s *testSyncSource) FragmentInfo(fragment int) (gotv.FragmentInfo, bool) {
+	f, ok := s.fragments[fragment]
+	return f, ok
+}
+
+func TestSelectSyncFragment(t *testing.T) {
+	asserts := assert.New(t)
+	ready := gotv.FragmentInfo{HasFull: true, HasDelta: true}
+	fullOnly := gotv.FragmentInfo{HasFull: true}
+	src := &testSyncSource{
+		match: gotv.MatchInfo{SignupFragment: 3, Latest: 20},
+		fragments: map[int]gotv.FragmentInfo{
+			3: fullOnly, 4: ready, 5: ready, 12: fullOnly, 13: ready, 20: fullOnly,
+		},
+	}
+	for _, td := range []struct {
+		title     string
+		latest    int
+		requested int
+		expected  int
+		err       error
+	}{
+		{title: "latest minus offset is ready", latest: 20, requested: 0, expected: 13},
+		{title: "latest minus offset is not ready", latest: 19, requested: 0, err: gotv.ErrFragmentNotFound},
+		{title: "latest minus offset is before signup", latest: 9, requested: 0, err: gotv.ErrFragmentNotFound},
+		{title: "requested before signup is clamped", latest: 20, requested: 1, expected: 4},
+		{title: "requested walks forward", latest: 20, requested: 6, expected: 13},
+		{title: "requested exact", latest: 20, requested: 5, expected: 5},
+		{title: "requested after last ready", latest: 20, requested: 14, err: gotv.ErrFragmentNotFound},
+	} {
+		t.Run(td.title, func(t *testing.T) {
+			src.match.Latest = td.latest
+			fragment, _, err := gotv.SelectSyncFragment(src, td.requested, time.Now())
+			if td.err != nil {
+				asserts.ErrorIs(err, td.err)
+				return
+			}
+			asserts.NoError(err)
+			asserts.Equal(td.expected, fragment)
+		})
+	}
+}
+
+func TestSelectSyncFragmentDelay(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+	fragments := map[int]gotv.FragmentInfo{}
+	for i := 1; i <= 20; i++ {
+		// fragment 20 is received now, fragment 1 is received 57 seconds ago
+		fragments[i] = gotv.FragmentInfo{At: now.Add(-time.Duration(20-i) * 3 * time.Second), HasFull: true, HasDelta: true}
+	}
+	src := &testSyncSource{
+		match:     gotv.MatchInfo{SignupFragment: 1, Latest: 20},
+		fragments: fragments,
+	}
+	for _, td := range []struct {
+		title     string
+		delay     time.Duration
+		requested int
+		expected  int
+		err       error
+	}{
+		{title: "no delay", delay: 0, requested: 0, expected: 13},
+		{title: "delay shorter than offset", delay: 10 * time.Second, requested: 0, expected: 13},
+		{title: "delay longer than offset", delay: 30 * time.Second, requested: 0, expected: 10},
+		{title: "delay longer than match", delay: time.Minute, requested: 0, err: gotv.ErrFragmentNotFound},
+		{title: "requested before cutoff", delay: 30 * time.Second, requested: 5, expected: 5},
+		{title: "requested after cutoff is clamped", delay: 30 * time.Second, requested: 15, expected: 10},
+		{title: "requested after cutoff without delay", delay: 0, requested: 15, expected: 15},
+	} {
+		t.Run(td.title, func(t *testing.T) {
+			src.match.Delay = td.delay
+			fragment, f, err := gotv.SelectSyncFragment(src, td.requested, now)
+			if td.err != nil {
+				asserts.ErrorIs(err, td.err)
+				return
+			}
+			asserts.NoError(err)
+			asserts.Equal(td.expected, fragment)
+			asserts.False(f.At.After(now.Add(-td.delay)))
+		})
+	}
+}
+
+func TestSelectSync(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+	src := &testSyncSource{
+		match: gotv.MatchInfo{SignupFragment: 1, Latest: 1, ReceivedAt: now.Add(-time.Second), TickPerSecond: 128, Map: "de_dust2"},
+		fragments: map[int]gotv.FragmentInfo{
+			1: {At: now.Add(-2 * time.Second), Tick: 100, EndTick: 228, HasFull: true, HasDelta: true},
+		},
+	}
+	s, err := gotv.SelectSync(src, 1, now)
+	asserts.NoError(err)
+	asserts.Equal(gotv.Sync{
+		Tick:           100,
+		Endtick:        228,
+		RealTimeDelay:  2,
+		ReceiveAge:     1,
+		Fragment:       1,
+		SignupFragment: 1,
+		TickPerSecond:  128,
+		Map:            "de_dust2",
+		Protocol:       gotv.DefaultProtocol,
+	}, s)
+}
+
+func TestEstimateKeyframeInterval(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+	for _, td := range []struct {
+		title    string
+		prev     gotv.FragmentInfo
+		cur      gotv.FragmentInfo
+		tps      float64
+		expected float64
+	}{
+		{title: "tick spacing", prev: gotv.FragmentInfo{Tick: 128}, cur: gotv.FragmentInfo{Tick: 512}, tps: 128, expected: 3},
+		{title: "timestamps without tps", prev: gotv.FragmentInfo{At: now}, cur: gotv.FragmentInfo{At: now.Add(2 * time.Second)}, tps: 0, expected: 2},
+		{title: "timestamps without tick", prev: gotv.FragmentInfo{At: now}, cur: gotv.FragmentInfo{At: now.Add(2 * time.Second)}, tps: 64, expected: 2},
+		{title: "unknown", prev: gotv.FragmentInfo{}, cur: gotv.FragmentInfo{}, tps: 128, expected: 0},
+	} {
+		t.Run(td.title, func(t *testing.T) {
+			asserts.Equal(td.expected, gotv.EstimateKeyframeInterval(td.prev, td.cur, td.tps))
+		})
+	}
+}
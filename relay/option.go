@@ -0,0 +1,37 @@
+package relay
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option Relay option
+type Option func(r *Relay)
+
+// WithHTTPClient requests upstream with c instead of http.DefaultClient
+func WithHTTPClient(c *http.Client) Option {
+	return func(r *Relay) {
+		r.c = c
+	}
+}
+
+// WithInterval waits d before polling fragment which is not ready yet. Default is 1 second.
+func WithInterval(d time.Duration) Option {
+	return func(r *Relay) {
+		r.interval = d
+	}
+}
+
+// WithMaxBackoff caps exponential backoff while upstream keeps returning 404 or failing. Default is 10 seconds.
+func WithMaxBackoff(d time.Duration) Option {
+	return func(r *Relay) {
+		r.maxBackoff = d
+	}
+}
+
+// WithClock uses c instead of the real clock
+func WithClock(c Clock) Option {
+	return func(r *Relay) {
+		r.clock = c
+	}
+}
@@ -0,0 +1,254 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+// errNotReady upstream returned 404 for fragment which is not received yet, or match which is not started yet
+var errNotReady = xerrors.New("Not Ready")
+
+// Clock time source of Relay. Replace it with fake clock to test backoff without waiting.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Lag how far Relay is behind upstream
+type Lag struct {
+	SignupFragment int           // signup fragment of the match
+	Fragment       int           // last fragment stored
+	Duration       time.Duration // time since upstream received Fragment. Time Relay stored it is used if upstream does not serve fragment metadata
+}
+
+// Relay pulls a match from upstream GOTV+ server, and feeds fragments into Store.
+// It follows latest fragment of upstream, so game server only needs to POST to upstream.
+type Relay struct {
+	sync.RWMutex
+	upstream   string       // URL of upstream match, e.g. "http://upstream:8080/gotv/<token>"
+	token      string       // token of the match in Store
+	s          gotv.StoreV2 // local Store
+	c          *http.Client
+	interval   time.Duration // wait before polling fragment which is not ready yet
+	maxBackoff time.Duration // max wait while upstream keeps returning 404 or failing
+	clock      Clock
+
+	started    bool      // start of synced.SignupFragment is stored. Signup fragment can be 0, so it is not a marker.
+	synced     gotv.Sync // last /sync response
+	fragment   int       // next fragment to pull
+	full       []byte    // full of fragment kept while its delta is not ready
+	fullOf     int       // fragment of full
+	stored     bool      // any fragment is stored
+	lastStored int       // last fragment stored
+	receivedAt time.Time // time upstream received lastStored
+}
+
+// get requests path under upstream match URL
+func (r *Relay) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.upstream+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(res.Body)
+	case http.StatusNotFound:
+		return nil, errNotReady
+	default:
+		return nil, xerrors.Errorf("GET %s: unexpected status %d", path, res.StatusCode)
+	}
+}
+
+// getJSON requests path and decodes JSON response into v
+func (r *Relay) getJSON(ctx context.Context, path string, v interface{}) error {
+	b, err := r.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// fragmentMetadata returns metadata of fragment. It is estimated from last sync if upstream does not serve it.
+func (r *Relay) fragmentMetadata(ctx context.Context, fragment int) gotv.FragmentMetadata {
+	m := gotv.FragmentMetadata{}
+	if err := r.getJSON(ctx, "/"+strconv.Itoa(fragment), &m); err == nil && m.Tick != 0 {
+		return m
+	}
+	// fragments are keyframe interval apart from sync fragment
+	ticks := int(math.Round(r.synced.KeyframeInterval * float64(r.synced.TickPerSecond)))
+	m = gotv.FragmentMetadata{Tick: r.synced.Tick + (fragment-r.synced.Fragment)*ticks}
+	m.EndTick = m.Tick + ticks
+	return m
+}
+
+// resync polls /sync, and starts the match again if signup fragment is changed e.g. by map change
+func (r *Relay) resync(ctx context.Context) error {
+	s := gotv.Sync{}
+	if err := r.getJSON(ctx, "/sync", &s); err != nil {
+		return err
+	}
+	if !r.started || s.SignupFragment != r.synced.SignupFragment {
+		body, err := r.get(ctx, fmt.Sprintf("/%d/start", s.SignupFragment))
+		if err != nil {
+			return err
+		}
+		if err := r.s.OnStart(ctx, r.token, s.SignupFragment, gotv.StartFrame{
+			At:               r.clock.Now(),
+			Tps:              float64(s.TickPerSecond),
+			KeyframeInterval: s.KeyframeInterval,
+			Protocol:         s.Protocol,
+			Map:              s.Map,
+			Body:             body,
+		}); err != nil {
+			return &storeError{err: err}
+		}
+		if r.fragment < s.SignupFragment {
+			r.fragment = s.SignupFragment
+		}
+	}
+	// upstream moved past next fragment, e.g. relay is started or it expired while upstream was unreachable
+	if s.Fragment > r.fragment {
+		r.fragment = s.Fragment
+	}
+	r.Lock()
+	r.started = true
+	r.synced = s
+	r.Unlock()
+	return nil
+}
+
+// pull pulls full and delta of next fragment. It returns whether the fragment is final.
+func (r *Relay) pull(ctx context.Context) (bool, error) {
+	fragment := r.fragment
+	// game server sends delta after full, so full is not downloaded again while waiting for delta
+	if r.full == nil || r.fullOf != fragment {
+		full, err := r.get(ctx, fmt.Sprintf("/%d/full", fragment))
+		if err != nil {
+			return false, err
+		}
+		r.full, r.fullOf = full, fragment
+	}
+	delta, err := r.get(ctx, fmt.Sprintf("/%d/delta", fragment))
+	if err != nil {
+		return false, err
+	}
+	m := r.fragmentMetadata(ctx, fragment)
+	// keep time upstream received the fragment, so delay of local Store is counted from it
+	at := r.clock.Now()
+	if m.Timestamp != 0 {
+		at = time.UnixMilli(m.Timestamp)
+	}
+	if err := r.s.OnFull(ctx, r.token, fragment, m.Tick, at, r.full); err != nil {
+		return false, &storeError{err: err}
+	}
+	if err := r.s.OnDelta(ctx, r.token, fragment, m.EndTick, at, m.Final, delta); err != nil {
+		return false, &storeError{err: err}
+	}
+	r.Lock()
+	r.stored = true
+	r.lastStored = fragment
+	r.receivedAt = at
+	r.Unlock()
+	r.full = nil
+	r.fragment++
+	return m.Final, nil
+}
+
+// storeError error returned by local Store. Run stops on it, while upstream errors are retried.
+type storeError struct {
+	err error
+}
+
+func (e *storeError) Error() string { return e.err.Error() }
+func (e *storeError) Unwrap() error { return e.err }
+
+// Run pulls fragments until the final fragment is stored, ctx is done or local Store returns error.
+// It returns nil after the final fragment.
+// 404 and other failures of upstream are retried with exponential backoff up to max backoff.
+func (r *Relay) Run(ctx context.Context) error {
+	backoff := r.interval
+	for {
+		var err error
+		var final bool
+		if !r.started {
+			err = r.resync(ctx)
+		} else if final, err = r.pull(ctx); xerrors.Is(err, errNotReady) {
+			// check new signup fragment while waiting for next fragment
+			if serr := r.resync(ctx); serr != nil && !xerrors.Is(serr, errNotReady) {
+				err = serr
+			}
+		}
+		if serr := (*storeError)(nil); xerrors.As(err, &serr) {
+			return serr.err
+		}
+		if final {
+			return nil
+		}
+		if err == nil {
+			backoff = r.interval
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.clock.After(backoff):
+		}
+		if backoff *= 2; backoff > r.maxBackoff {
+			backoff = r.maxBackoff
+		}
+	}
+}
+
+// Lag returns how far Relay is behind upstream
+func (r *Relay) Lag() Lag {
+	r.RLock()
+	defer r.RUnlock()
+	if !r.stored {
+		return Lag{SignupFragment: r.synced.SignupFragment}
+	}
+	return Lag{
+		SignupFragment: r.synced.SignupFragment,
+		Fragment:       r.lastStored,
+		Duration:       r.clock.Now().Sub(r.receivedAt),
+	}
+}
+
+// New Get new pointer of Relay which pulls upstream match URL into token of s.
+func New(upstream string, token string, s gotv.StoreV2, opts ...Option) *Relay {
+	r := &Relay{
+		upstream:   strings.TrimSuffix(upstream, "/"),
+		token:      token,
+		s:          s,
+		c:          http.DefaultClient,
+		interval:   time.Second,
+		maxBackoff: 10 * time.Second,
+		clock:      realClock{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
@@ -0,0 +1,249 @@
+package relay_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+	"github.com/FlowingSPDG/gotv-plus-go/relay"
+)
+
+func TestRelay(t *testing.T) {
+	asserts := assert.New(t)
+	origin := inmemory.NewInmemoryGOTV("gopher")
+	defer origin.Close()
+	var notFound, fulls21, deltas21 int64
+	h := http.StripPrefix("/gotv", gotv.NewHTTPHandler(gotv.WrapStore(origin), gotv.WrapBroadcaster(origin)))
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asserts.Equal(http.MethodGet, r.Method)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code == http.StatusNotFound {
+			atomic.AddInt64(&notFound, 1)
+		}
+		if rec.Code == http.StatusOK && r.URL.Path == "/gotv/match/21/full" {
+			atomic.AddInt64(&fulls21, 1)
+		}
+		if rec.Code == http.StatusNotFound && r.URL.Path == "/gotv/match/21/delta" {
+			atomic.AddInt64(&deltas21, 1)
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+	defer upstream.Close()
+
+	edge := inmemory.NewInmemoryGOTV("gopher")
+	defer edge.Close()
+	r := relay.New(upstream.URL+"/gotv/match/", "edge", gotv.WrapStore(edge), relay.WithInterval(5*time.Millisecond), relay.WithMaxBackoff(20*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	// upstream match is not started yet
+	asserts.Eventually(func() bool { return atomic.LoadInt64(&notFound) >= 3 }, time.Second, time.Millisecond)
+	asserts.Equal(relay.Lag{}, r.Lag())
+
+	at := time.Now().Add(-2 * time.Second)
+	asserts.NoError(origin.OnStart("match", 1, gotv.StartFrame{At: at, Tps: 128, KeyframeInterval: 3, Map: "de_dust2", Protocol: 4, Body: []byte("start")}))
+	send := func(from, to int) {
+		for i := from; i <= to; i++ {
+			asserts.NoError(origin.OnFull("match", i, i*384, at, []byte("full"+strings.Repeat("!", i))))
+			asserts.NoError(origin.OnDelta("match", i, (i+1)*384, at, false, []byte("delta")))
+		}
+	}
+	send(1, 10)
+	asserts.Eventually(func() bool { return r.Lag().Fragment == 10 }, time.Second, 5*time.Millisecond)
+
+	// relay starts from sync fragment of upstream, and copies start, fragments and metadata
+	b, err := edge.GetStart("edge", 1)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start"), b)
+	s, err := edge.GetSync("edge", 5)
+	asserts.NoError(err)
+	asserts.Equal(5*384, s.Tick)
+	asserts.Equal("de_dust2", s.Map)
+	asserts.Equal(3.0, s.KeyframeInterval)
+	b, err = edge.GetFull("edge", 10)
+	asserts.NoError(err)
+	asserts.Equal([]byte("full!!!!!!!!!!"), b)
+	m, err := edge.GetFragmentMetadata(context.Background(), "edge", 10)
+	asserts.NoError(err)
+	asserts.Equal(11*384, m.EndTick)
+	asserts.Equal(at.UnixMilli(), m.Timestamp)
+	lag := r.Lag()
+	asserts.Equal(1, lag.SignupFragment)
+	asserts.GreaterOrEqual(lag.Duration, 2*time.Second)
+
+	send(11, 12)
+	asserts.Eventually(func() bool { return r.Lag().Fragment == 12 }, time.Second, 5*time.Millisecond)
+
+	// map change starts new signup fragment
+	asserts.NoError(origin.OnStart("match", 13, gotv.StartFrame{At: at, Tps: 128, KeyframeInterval: 3, Map: "de_inferno", Protocol: 4, Body: []byte("start2")}))
+	send(13, 20)
+	// new signup fragment is found by sync while waiting for fragment 21
+	asserts.Eventually(func() bool { lag := r.Lag(); return lag.Fragment == 20 && lag.SignupFragment == 13 }, time.Second, 5*time.Millisecond)
+	b, err = edge.GetStart("edge", 13)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start2"), b)
+	s, err = edge.GetSyncLatest("edge")
+	asserts.NoError(err)
+	asserts.Equal(13, s.SignupFragment)
+	asserts.Equal("de_inferno", s.Map)
+
+	// full is kept while delta is not received yet
+	asserts.NoError(origin.OnFull("match", 21, 21*384, at, []byte("full")))
+	asserts.Eventually(func() bool { return atomic.LoadInt64(&deltas21) >= 3 }, time.Second, time.Millisecond)
+	asserts.Equal(int64(1), atomic.LoadInt64(&fulls21))
+
+	// relay stops after the final fragment
+	asserts.NoError(origin.OnDelta("match", 21, 22*384, at, true, []byte("delta")))
+	select {
+	case err := <-done:
+		asserts.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("relay keeps polling after final fragment")
+	}
+	m, err = edge.GetFragmentMetadata(context.Background(), "edge", 21)
+	asserts.NoError(err)
+	asserts.True(m.Final)
+	cancel()
+}
+
+// fakeClock fires immediately and records waits
+type fakeClock struct {
+	sync.Mutex
+	waits  []time.Duration
+	onWait func(n int) bool // called with number of waits so far. The wait never fires if it returns false
+}
+
+func (c *fakeClock) Now() time.Time { return time.Now() }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Lock()
+	c.waits = append(c.waits, d)
+	n := len(c.waits)
+	c.Unlock()
+	ch := make(chan time.Time, 1)
+	if c.onWait(n) {
+		ch <- time.Now()
+	}
+	return ch
+}
+
+func TestRelayBackoff(t *testing.T) {
+	asserts := assert.New(t)
+	origin := inmemory.NewInmemoryGOTV("gopher")
+	defer origin.Close()
+	upstream := httptest.NewServer(http.StripPrefix("/gotv", gotv.NewHTTPHandler(gotv.WrapStore(origin), gotv.WrapBroadcaster(origin))))
+	defer upstream.Close()
+
+	edge := inmemory.NewInmemoryGOTV("gopher")
+	defer edge.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := &fakeClock{onWait: func(n int) bool {
+		switch n {
+		case 3:
+			// match starts while relay backs off
+			now := time.Now()
+			asserts.NoError(origin.OnStart("match", 1, gotv.StartFrame{At: now, Tps: 128, Body: []byte("start")}))
+			for i := 1; i <= 10; i++ {
+				asserts.NoError(origin.OnFull("match", i, i*384, now, []byte("full")))
+				asserts.NoError(origin.OnDelta("match", i, (i+1)*384, now, false, []byte("delta")))
+			}
+		case 6:
+			cancel()
+			return false
+		}
+		return true
+	}}
+	r := relay.New(upstream.URL+"/gotv/match", "edge", gotv.WrapStore(edge), relay.WithInterval(5*time.Millisecond), relay.WithMaxBackoff(20*time.Millisecond), relay.WithClock(clock))
+	asserts.ErrorIs(r.Run(ctx), context.Canceled)
+	// backoff doubles up to max backoff while match is not started, and again while fragment 11 is not received
+	ms := time.Millisecond
+	asserts.Equal([]time.Duration{5 * ms, 10 * ms, 20 * ms, 5 * ms, 10 * ms, 20 * ms}, clock.waits)
+	asserts.Equal(10, r.Lag().Fragment)
+}
+
+func TestRelaySignupFragmentZero(t *testing.T) {
+	asserts := assert.New(t)
+	origin := inmemory.NewInmemoryGOTV("gopher")
+	defer origin.Close()
+	now := time.Now()
+	asserts.NoError(origin.OnStart("match", 0, gotv.StartFrame{At: now, Tps: 128, Body: []byte("start")}))
+	for i := 0; i <= 10; i++ {
+		asserts.NoError(origin.OnFull("match", i, (i+1)*384, now, []byte("full")))
+		asserts.NoError(origin.OnDelta("match", i, (i+2)*384, now, i == 10, []byte("delta")))
+	}
+	var syncs int64
+	h := http.StripPrefix("/gotv", gotv.NewHTTPHandler(gotv.WrapStore(origin), gotv.WrapBroadcaster(origin)))
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gotv/match/sync" {
+			atomic.AddInt64(&syncs, 1)
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer upstream.Close()
+
+	edge := inmemory.NewInmemoryGOTV("gopher")
+	defer edge.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	r := relay.New(upstream.URL+"/gotv/match", "edge", gotv.WrapStore(edge), relay.WithInterval(5*time.Millisecond))
+	asserts.NoError(r.Run(ctx))
+	// start of fragment 0 is stored once, and relay does not keep polling sync
+	asserts.Equal(int64(1), atomic.LoadInt64(&syncs))
+	b, err := edge.GetStart("edge", 0)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start"), b)
+	lag := r.Lag()
+	asserts.Equal(0, lag.SignupFragment)
+	asserts.Equal(10, lag.Fragment)
+}
+
+func TestRelayStoreError(t *testing.T) {
+	asserts := assert.New(t)
+	origin := inmemory.NewInmemoryGOTV("gopher")
+	defer origin.Close()
+	now := time.Now()
+	asserts.NoError(origin.OnStart("match", 1, gotv.StartFrame{At: now, Tps: 128, Body: []byte("start")}))
+	for i := 1; i <= 10; i++ {
+		asserts.NoError(origin.OnFull("match", i, i*384, now, []byte("full")))
+		asserts.NoError(origin.OnDelta("match", i, (i+1)*384, now, false, []byte("delta")))
+	}
+	upstream := httptest.NewServer(http.StripPrefix("/gotv", gotv.NewHTTPHandler(gotv.WrapStore(origin), gotv.WrapBroadcaster(origin))))
+	defer upstream.Close()
+
+	// local Store rejects fragments after it dropped the match
+	edge := inmemory.NewInmemoryGOTV("gopher")
+	defer edge.Close()
+	r := relay.New(upstream.URL+"/gotv/match", "edge", &dropStore{StoreV2: gotv.WrapStore(edge), m: edge}, relay.WithInterval(5*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	asserts.ErrorIs(r.Run(ctx), gotv.ErrMatchNotFound)
+}
+
+// dropStore deletes the match right after start
+type dropStore struct {
+	gotv.StoreV2
+	m *inmemory.InMemory
+}
+
+func (d *dropStore) OnStart(ctx context.Context, token string, fragment int, f gotv.StartFrame) error {
+	if err := d.StoreV2.OnStart(ctx, token, fragment, f); err != nil {
+		return err
+	}
+	return d.m.DeleteMatch(ctx, token)
+}
@@ -0,0 +1,38 @@
+package replay
+
+import (
+	"time"
+)
+
+// Option Replay option
+type Option func(r *Replay)
+
+// WithSpeed replays speed times as fast as the original. Default is 1. Ignored unless speed is positive.
+func WithSpeed(speed float64) Option {
+	return func(r *Replay) {
+		if speed > 0 {
+			r.speed = speed
+		}
+	}
+}
+
+// WithOffset starts replay from fragment at offset of the match time. Only the first pass is skipped while looping.
+func WithOffset(offset time.Duration) Option {
+	return func(r *Replay) {
+		r.offset = offset
+	}
+}
+
+// WithLoop replays the match again from the beginning after the last fragment, until Run is cancelled
+func WithLoop() Option {
+	return func(r *Replay) {
+		r.loop = true
+	}
+}
+
+// WithClock uses c instead of the real clock
+func WithClock(c Clock) Option {
+	return func(r *Replay) {
+		r.clock = c
+	}
+}
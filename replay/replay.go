@@ -0,0 +1,236 @@
+package replay
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+)
+
+// ErrNoFragment the source match has no fragment to replay
+var ErrNoFragment = xerrors.New("No Fragment To Replay")
+
+// ErrMissingFragment is gotv.ErrMissingFragment, kept for callers of Run
+var ErrMissingFragment = gotv.ErrMissingFragment
+
+// Clock time source of Replay. Replace it with fake clock to test without waiting.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Replay replays a stored match from Broadcaster into Store under another token, as if the game server is broadcasting it live.
+// Fragments are written with the original interval between them divided by speed.
+// Fragment numbers are renumbered from 1, so every loop continues after the previous one with new signup fragment.
+type Replay struct {
+	b      gotv.BroadcasterV2
+	source string // token of the stored match
+	s      gotv.StoreV2
+	token  string // token to replay the match as
+
+	speed  float64       // 2 replays twice as fast
+	offset time.Duration // match time to skip on the first pass
+	loop   bool          // replay again from the beginning after the last fragment
+	clock  Clock
+}
+
+// fragment one fragment read from source
+type fragment struct {
+	tick    int
+	endTick int
+	final   bool
+	at      time.Time // time source received the fragment. zero if unknown
+	full    []byte
+	delta   []byte
+}
+
+// readSource reads source match, and returns ErrNoFragment if it has no fragment with full fragment
+func (r *Replay) readSource(ctx context.Context) (gotv.Recording, error) {
+	src, err := gotv.ReadRecording(ctx, r.b, r.source)
+	if xerrors.Is(err, gotv.ErrFragmentNotFound) {
+		return gotv.Recording{}, ErrNoFragment
+	}
+	return src, err
+}
+
+// read reads fragment n of source. Ticks are estimated from src if source does not serve fragment metadata.
+func (r *Replay) read(ctx context.Context, src gotv.Recording, n int) (fragment, error) {
+	full, err := r.b.GetFull(ctx, r.source, n)
+	if err != nil {
+		return fragment{}, err
+	}
+	delta, err := r.b.GetDelta(ctx, r.source, n)
+	if err != nil {
+		return fragment{}, err
+	}
+	f := fragment{full: full, delta: delta}
+	if m, ok := gotv.GetFragmentMetadataOf(ctx, r.b, r.source, n); ok {
+		f.tick, f.endTick, f.final = m.Tick, m.EndTick, m.Final
+		if m.Timestamp != 0 {
+			f.at = time.UnixMilli(m.Timestamp)
+		}
+		return f, nil
+	}
+	// fragments are keyframe interval apart from the first fragment
+	ticks := int(math.Round(src.KeyframeInterval * src.TickPerSecond))
+	f.tick = src.FirstTick + (n-src.First)*ticks
+	f.endTick = f.tick + ticks
+	return f, nil
+}
+
+// interval returns original time between prev and cur fragments
+func interval(src gotv.Recording, prev, cur fragment) time.Duration {
+	if !prev.at.IsZero() && cur.at.After(prev.at) {
+		return cur.at.Sub(prev.at)
+	}
+	if src.TickPerSecond > 0 && cur.tick > prev.tick {
+		return time.Duration(float64(cur.tick-prev.tick) / src.TickPerSecond * float64(time.Second))
+	}
+	return time.Duration(src.KeyframeInterval * float64(time.Second))
+}
+
+// wait waits until t
+func (r *Replay) wait(ctx context.Context, t time.Time) error {
+	d := t.Sub(r.clock.Now())
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.clock.After(d):
+		return nil
+	}
+}
+
+// pass where the next pass of the replay continues
+type pass struct {
+	out  int       // fragment number to write
+	at   time.Time // time to write start and the first fragment. zero to write them now
+	tick int       // tick of the first fragment, so ticks keep increasing for viewers of the previous pass. zero to keep ticks of source
+}
+
+// play replays source once from offset, continuing from p. It returns where the next pass continues as if the match continued.
+func (r *Replay) play(ctx context.Context, p pass, offset time.Duration, last bool) (pass, error) {
+	out := p.out
+	src, err := r.readSource(ctx)
+	if err != nil {
+		return p, err
+	}
+	start, err := r.b.GetStart(ctx, r.source, src.SignupFragment)
+	if err != nil {
+		return p, err
+	}
+
+	var prev fragment
+	var gap time.Duration     // match time since the previous fragment
+	var elapsed time.Duration // match time since the first fragment
+	var next time.Time        // time to write the fragment
+	var shift int             // added to ticks of source
+	started := false
+	for n := src.First; src.Latest == 0 || n <= src.Latest; n++ {
+		f, err := r.read(ctx, src, n)
+		if xerrors.Is(err, gotv.ErrFragmentNotFound) {
+			if src.Latest == 0 && n > src.First {
+				// latest fragment is unknown without gotv.MatchInfoBroadcaster
+				break
+			}
+			return p, xerrors.Errorf("fragment %d: %w", n, ErrMissingFragment)
+		}
+		if err != nil {
+			return p, err
+		}
+		gap = 0
+		if n > src.First {
+			gap = interval(src, prev, f)
+		}
+		elapsed += gap
+		prev = f
+		if elapsed < offset && !f.final {
+			continue
+		}
+
+		if !started {
+			if !p.at.IsZero() {
+				if err := r.wait(ctx, p.at); err != nil {
+					return p, err
+				}
+			}
+			if p.tick != 0 {
+				shift = p.tick - f.tick
+			}
+			next = r.clock.Now()
+			if err := r.s.OnStart(ctx, r.token, out, gotv.StartFrame{
+				At:               next,
+				Tick:             f.tick + shift,
+				Tps:              src.TickPerSecond,
+				KeyframeInterval: src.KeyframeInterval,
+				Protocol:         src.Protocol,
+				Map:              src.Map,
+				Body:             start,
+			}); err != nil {
+				return p, err
+			}
+			started = true
+		} else {
+			next = next.Add(time.Duration(float64(gap) / r.speed))
+			if err := r.wait(ctx, next); err != nil {
+				return p, err
+			}
+		}
+
+		now := r.clock.Now()
+		if err := r.s.OnFull(ctx, r.token, out, f.tick+shift, now, f.full); err != nil {
+			return p, err
+		}
+		// viewers keep watching while looping
+		if err := r.s.OnDelta(ctx, r.token, out, f.endTick+shift, now, f.final && last, f.delta); err != nil {
+			return p, err
+		}
+		out++
+		if f.final {
+			break
+		}
+	}
+	if !started {
+		return p, ErrNoFragment
+	}
+	if gap <= 0 {
+		gap = time.Duration(src.KeyframeInterval * float64(time.Second))
+	}
+	return pass{out: out, at: next.Add(time.Duration(float64(gap) / r.speed)), tick: prev.endTick + shift}, nil
+}
+
+// Run replays the match until the last fragment, or until ctx is done if looping.
+// Every loop starts one fragment interval after the last fragment of the previous one, and its ticks continue from the end tick of it.
+func (r *Replay) Run(ctx context.Context) error {
+	p, err := r.play(ctx, pass{out: 1}, r.offset, !r.loop)
+	for err == nil && r.loop {
+		p, err = r.play(ctx, p, 0, false)
+	}
+	return err
+}
+
+// New Get new pointer of Replay which replays source match of b into token of s.
+func New(b gotv.BroadcasterV2, source string, s gotv.StoreV2, token string, opts ...Option) *Replay {
+	r := &Replay{
+		b:      b,
+		source: source,
+		s:      s,
+		token:  token,
+		speed:  1,
+		clock:  realClock{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
@@ -0,0 +1,174 @@
+package replay_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlowingSPDG/gotv-plus-go/examples/inmemory"
+	"github.com/FlowingSPDG/gotv-plus-go/gotv"
+	"github.com/FlowingSPDG/gotv-plus-go/replay"
+)
+
+// fakeClock advances immediately and records waits
+type fakeClock struct {
+	sync.Mutex
+	now    time.Time
+	waits  []time.Duration
+	stopAt int    // wait which never fires, 0 for none
+	stop   func() // called at stopAt
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Lock()
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if len(c.waits) == c.stopAt {
+		c.stop()
+	} else {
+		ch <- c.now
+	}
+	c.Unlock()
+	return ch
+}
+
+// newSource returns stored match "final" with fragments 5, 6 and 7 received 3s, 4s apart
+func newSource(asserts *assert.Assertions) *inmemory.InMemory {
+	// delay does not hide stored fragments from replay
+	m := inmemory.NewInmemoryGOTV("gopher", inmemory.WithDelay(48*time.Hour))
+	t0 := time.Now().Add(-24 * time.Hour)
+	asserts.NoError(m.OnStart("final", 5, gotv.StartFrame{At: t0, Tps: 128, KeyframeInterval: 3, Protocol: 4, Map: "de_inferno", Body: []byte("start")}))
+	for i, at := range []time.Duration{0, 3 * time.Second, 7 * time.Second} {
+		n := 5 + i
+		asserts.NoError(m.OnFull("final", n, n*384, t0.Add(at), []byte{'f', byte('0' + n)}))
+		asserts.NoError(m.OnDelta("final", n, (n+1)*384, t0.Add(at), n == 7, []byte{'d', byte('0' + n)}))
+	}
+	return m
+}
+
+func TestReplay(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	src := newSource(asserts)
+	defer src.Close()
+	dst := inmemory.NewInmemoryGOTV("gopher")
+	defer dst.Close()
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	r := replay.New(gotv.WrapBroadcaster(src), "final", gotv.WrapStore(dst), "watchparty", replay.WithSpeed(2), replay.WithClock(clock))
+	asserts.NoError(r.Run(ctx))
+	asserts.Equal([]time.Duration{1500 * time.Millisecond, 2 * time.Second}, clock.waits)
+
+	b, err := dst.GetStart("watchparty", 1)
+	asserts.NoError(err)
+	asserts.Equal([]byte("start"), b)
+	td := []struct {
+		fragment int
+		full     string
+		delta    string
+		tick     int
+		endTick  int
+		at       time.Time
+		final    bool
+	}{
+		{fragment: 1, full: "f5", delta: "d5", tick: 5 * 384, endTick: 6 * 384, at: time.Unix(1700000000, 0)},
+		{fragment: 2, full: "f6", delta: "d6", tick: 6 * 384, endTick: 7 * 384, at: time.Unix(1700000001, 500000000)},
+		{fragment: 3, full: "f7", delta: "d7", tick: 7 * 384, endTick: 8 * 384, at: time.Unix(1700000003, 500000000), final: true},
+	}
+	for _, tt := range td {
+		full, err := dst.GetFull("watchparty", tt.fragment)
+		asserts.NoError(err)
+		asserts.Equal(tt.full, string(full))
+		delta, err := dst.GetDelta("watchparty", tt.fragment)
+		asserts.NoError(err)
+		asserts.Equal(tt.delta, string(delta))
+		m, err := dst.GetFragmentMetadata(ctx, "watchparty", tt.fragment)
+		asserts.NoError(err)
+		asserts.Equal(tt.tick, m.Tick)
+		asserts.Equal(tt.endTick, m.EndTick)
+		asserts.Equal(tt.at.UnixMilli(), m.Timestamp)
+		asserts.Equal(tt.final, m.Final)
+	}
+	s, err := dst.GetSync("watchparty", 1)
+	asserts.NoError(err)
+	asserts.Equal("de_inferno", s.Map)
+	asserts.Equal(128, s.TickPerSecond)
+
+	_, err = dst.GetFull("watchparty", 4)
+	asserts.ErrorIs(err, gotv.ErrFragmentNotFound)
+}
+
+func TestReplayOffset(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	src := newSource(asserts)
+	defer src.Close()
+	dst := inmemory.NewInmemoryGOTV("gopher")
+	defer dst.Close()
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	err := replay.New(gotv.WrapBroadcaster(src), "final", gotv.WrapStore(dst), "watchparty", replay.WithOffset(3*time.Second), replay.WithClock(clock)).Run(ctx)
+	asserts.NoError(err)
+	asserts.Equal([]time.Duration{4 * time.Second}, clock.waits)
+
+	// replay starts from the second fragment
+	full, err := dst.GetFull("watchparty", 1)
+	asserts.NoError(err)
+	asserts.Equal("f6", string(full))
+	full, err = dst.GetFull("watchparty", 2)
+	asserts.NoError(err)
+	asserts.Equal("f7", string(full))
+
+	err = replay.New(gotv.WrapBroadcaster(src), "unknown", gotv.WrapStore(dst), "watchparty", replay.WithClock(clock)).Run(ctx)
+	asserts.ErrorIs(err, gotv.ErrMatchNotFound)
+
+	// hole before the final fragment is not skipped silently
+	asserts.NoError(src.DeleteFragment(ctx, "final", 6))
+	err = replay.New(gotv.WrapBroadcaster(src), "final", gotv.WrapStore(dst), "watchparty", replay.WithClock(clock)).Run(ctx)
+	asserts.ErrorIs(err, replay.ErrMissingFragment)
+}
+
+func TestReplayLoop(t *testing.T) {
+	asserts := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := newSource(asserts)
+	defer src.Close()
+	dst := inmemory.NewInmemoryGOTV("gopher")
+	defer dst.Close()
+
+	// stop in the third pass
+	clock := &fakeClock{now: time.Unix(1700000000, 0), stopAt: 7, stop: cancel}
+	err := replay.New(gotv.WrapBroadcaster(src), "final", gotv.WrapStore(dst), "watchparty", replay.WithLoop(), replay.WithClock(clock)).Run(ctx)
+	asserts.ErrorIs(err, context.Canceled)
+	// next pass starts one interval after the last fragment
+	asserts.Equal([]time.Duration{3 * time.Second, 4 * time.Second, 4 * time.Second, 3 * time.Second, 4 * time.Second, 4 * time.Second, 3 * time.Second}, clock.waits)
+
+	// every pass starts with new signup fragment, and no fragment is final while looping
+	s, err := dst.GetSync("watchparty", 7)
+	asserts.NoError(err)
+	asserts.Equal(7, s.SignupFragment)
+	// ticks keep increasing across passes for viewers who never fetch start again
+	endTick := 5 * 384
+	for fragment := 1; fragment <= 7; fragment++ {
+		m, err := dst.GetFragmentMetadata(ctx, "watchparty", fragment)
+		asserts.NoError(err)
+		asserts.False(m.Final)
+		asserts.Equal(endTick, m.Tick, "fragment %d", fragment)
+		asserts.Greater(m.EndTick, m.Tick)
+		endTick = m.EndTick
+	}
+	full, err := dst.GetFull("watchparty", 4)
+	asserts.NoError(err)
+	asserts.Equal("f5", string(full))
+}